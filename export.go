@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/renato0307/p2p-estimator/pkg/history"
+)
+
+// ExportCommand exports the rounds estimated in past sessions.
+const ExportCommand = "export"
+
+// runExport writes the history to stdout or to a file.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet(ExportCommand, flag.ExitOnError)
+	formatFlag := fs.String("format", history.Markdown, "export format: csv, json or markdown")
+	outputFlag := fs.String("o", "", "file to write to, defaults to stdout")
+	roomFlag := fs.String("room", "", "only export the rounds of this room")
+	historyFlag := fs.String("history", "", "history file. defaults to a file in the user config dir")
+	fs.Parse(args)
+
+	historyPath, err := historyPath(*historyFlag)
+	if err != nil {
+		return err
+	}
+	rounds, err := history.NewStore(historyPath).Rounds(*roomFlag)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *outputFlag != "" {
+		f, err := os.Create(*outputFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := history.Export(w, rounds, *formatFlag); err != nil {
+		return fmt.Errorf("error exporting history: %w", err)
+	}
+	return nil
+}
+
+// historyPath returns the path given or the default one.
+func historyPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	return history.DefaultPath()
+}
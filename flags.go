@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// multiaddrList is a flag that can be repeated to collect several addresses.
+type multiaddrList []multiaddr.Multiaddr
+
+func (l *multiaddrList) String() string {
+	addrs := make([]string, len(*l))
+	for i, addr := range *l {
+		addrs[i] = addr.String()
+	}
+	return strings.Join(addrs, ",")
+}
+
+func (l *multiaddrList) Set(value string) error {
+	addr, err := multiaddr.NewMultiaddr(value)
+	if err != nil {
+		return err
+	}
+	// bootstrap addresses must include the peer ID, e.g. /ip4/1.2.3.4/tcp/4001/p2p/QmPeer
+	if _, err := peer.AddrInfoFromP2pAddr(addr); err != nil {
+		return err
+	}
+	*l = append(*l, addr)
+	return nil
+}
+
+// AddrInfos groups the addresses by peer.
+func (l multiaddrList) AddrInfos() []peer.AddrInfo {
+	// addresses were validated when the flag was set
+	infos, _ := peer.AddrInfosFromP2pAddrs(l...)
+	return infos
+}
@@ -2,17 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+	"github.com/renato0307/p2p-estimator/pkg/api"
+	"github.com/renato0307/p2p-estimator/pkg/bot"
 	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/discovery"
+	"github.com/renato0307/p2p-estimator/pkg/history"
+	"github.com/renato0307/p2p-estimator/pkg/identity"
+	"github.com/renato0307/p2p-estimator/pkg/jira"
+	"github.com/renato0307/p2p-estimator/pkg/logging"
+	"github.com/renato0307/p2p-estimator/pkg/nat"
+	"github.com/renato0307/p2p-estimator/pkg/session"
 	"github.com/renato0307/p2p-estimator/pkg/ui"
+	"github.com/renato0307/p2p-estimator/pkg/web"
 
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
@@ -24,69 +40,216 @@ const DiscoveryInterval = time.Hour
 // DiscoveryServiceTag is used in our mDNS advertisements to discover other chat peers.
 const DiscoveryServiceTag = "pubsub-chat-example"
 
+// HistoryBufSize is the number of rounds to buffer while they are
+// written to the history.
+const HistoryBufSize = 64
+
+// BootstrapCommand runs a headless DHT server other peers use to find each
+// other over the internet.
+const BootstrapCommand = "bootstrap"
+
+// BotCommand joins a room without the text UI, reading commands from stdin
+// and writing the events of the room to stdout.
+const BotCommand = "bot"
+
+var logger = logging.Logger("main")
+
 func main() {
+	// the first argument can select a command, the default is to join a room
+	command := ""
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == BootstrapCommand || args[0] == BotCommand || args[0] == ExportCommand) {
+		command, args = args[0], args[1:]
+	}
+
+	if command == ExportCommand {
+		if err := runExport(args); err != nil {
+			exit(ExitFailure, "%s", err)
+		}
+		return
+	}
+
 	// parse some flags to set our nickname and the room to join
 	nickFlag := flag.String("nick", "", "nickname to use in estimation room. will be generated if empty")
 	roomFlag := flag.String("room", "awesome-estimation-room", "name of chat room to join")
+	codecFlag := flag.String("codec", string(chatroom.CodecAuto), "encoding of the messages: auto uses protobuf once every peer reads it, json or protobuf, which only encodes the envelope and keeps the payloads in JSON")
+	roomSecretFlag := flag.String("room-secret", "", "passphrase to encrypt the room messages. all peers must use the same")
 	ipAddressFlag := flag.String("addr", "0.0.0.0", "the ipv4 address to listen")
 	ipPortFlag := flag.String("port", "0", "the ipv4 port to listen")
-	// TODO: uncomment when handling public nodes discovery
-	// bootstrapServerAddressFlag := flag.String("bootstrap-addr", "", "address of the bootstrap server")
-	flag.Parse()
+	var bootstrapAddrs multiaddrList
+	flag.Var(&bootstrapAddrs, "bootstrap-addr", "address of a bootstrap server, can be repeated")
+	var relayAddrs multiaddrList
+	flag.Var(&relayAddrs, "relay-addr", "address of a circuit relay, can be repeated. defaults to the bootstrap servers")
+	relayFlag := flag.Bool("relay", false, "act as a circuit relay for peers behind NATs")
+	deckFlag := flag.String("deck", deck.Default.Name, "deck to use when starting a room: fibonacci, modified-fibonacci, powers-of-two, t-shirt, hours or a custom one")
+	deckFileFlag := flag.String("deck-file", "", "JSON file with custom decks")
+	jiraURLFlag := flag.String("jira-url", "", "base URL of jira, defaults to $JIRA_BASE_URL. the token is read from $JIRA_API_TOKEN")
+	jiraFieldFlag := flag.String("jira-story-points-field", "", "ID of the jira story points field, defaults to $JIRA_STORY_POINTS_FIELD or "+jira.DefaultStoryPointsField)
+	historyFlag := flag.String("history", "", "file where estimated rounds are kept. defaults to a file in the user config dir")
+	apiFlag := flag.String("api", "", "serve the local JSON API on this address, e.g. :7070 or unix:/path/to/socket")
+	webFlag := flag.String("web", "", "serve a browser UI on this address, e.g. :8080, instead of the text UI")
+	allowRemoteFlag := flag.Bool("allow-remote", false, "let -web and -api listen on addresses other machines can reach, e.g. 0.0.0.0:8080")
+	identityFlag := flag.String("identity", "", "file with the private key of this peer. defaults to a file in the user config dir")
+	logFileFlag := flag.String("log-file", "", "file where the logs are written, rotated as it grows. defaults to a file in the user config dir")
+	logLevelFlag := flag.String("log-level", logging.DefaultLevel, "minimum level of the logs, including the ones of libp2p: debug, info, warn or error")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [%s|%s|%s] [flags]\n", os.Args[0], BootstrapCommand, BotCommand, ExportCommand)
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
 
+	var err error
 	ctx := context.Background()
-	// TODO: uncomment when handling public nodes discovery
-	// serverMode := bootstrapServerAddressFlag == nil || *bootstrapServerAddressFlag == ""
+	serverMode := command == BootstrapCommand
 	room := *roomFlag // join the room from the cli flag, or the flag default
 
+	// the text UI shows what happens in its activity pane, the other modes
+	// use the standard logger
+	var events *activity.Log
+	if !serverMode && command != BotCommand && *webFlag == "" {
+		events = activity.New()
+	}
+
+	// logs go to a file, so they don't write over the text UI. the other
+	// modes show them too
+	logPath := *logFileFlag
+	if logPath == "" {
+		logPath, err = logging.LockDefault()
+		if err != nil {
+			exit(ExitFailure, "can't find where to keep the logs, set it with -log-file: %s", err)
+		}
+	}
+	logFile, err := logging.Setup(logging.Config{
+		File:    logPath,
+		Level:   *logLevelFlag,
+		Console: events == nil,
+	})
+	if errors.Is(err, logging.ErrInvalidLevel) {
+		exit(ExitUsage, "invalid -log-level %q, use debug, info, warn or error", *logLevelFlag)
+	}
+	if err != nil {
+		exit(ExitFailure, "can't write the logs to %s: %s", logPath, err)
+	}
+	defer logFile.Close()
+
+	// the API and the web UI drive the room, so only this machine can use
+	// them unless allowed
+	for name, addr := range map[string]string{"api": *apiFlag, "web": *webFlag} {
+		if addr == "" || *allowRemoteFlag {
+			continue
+		}
+		if err := api.CheckLocal(addr); err != nil {
+			exit(ExitUsage, "invalid -%s %q: %s, use a loopback one like localhost:8080 or pass -allow-remote to allow it", name, addr, err)
+		}
+	}
+
+	// the room agrees on one of the decks, by default it uses ours
+	decks := deck.Defaults()
+	if *deckFileFlag != "" {
+		customDecks, err := deck.Load(*deckFileFlag)
+		if err != nil {
+			exit(ExitUsage, "can't load the decks of %s: %s", *deckFileFlag, err)
+		}
+		decks = append(decks, customDecks...)
+	}
+	initialDeck, ok := deck.Find(decks, *deckFlag)
+	if !ok {
+		names := make([]string, len(decks))
+		for i, d := range decks {
+			names[i] = d.Name
+		}
+		exit(ExitUsage, "unknown deck %q, use one of %s or add it with -deck-file", *deckFlag, strings.Join(names, ", "))
+	}
+
+	historyPath, err := historyPath(*historyFlag)
+	if err != nil {
+		exit(ExitFailure, "can't find where to keep the history, set it with -history: %s", err)
+	}
+	historyStore := history.NewStore(historyPath)
+
+	// jira is optional, without it the update jira option shows how to set it up
+	jiraConfig := jira.ConfigFromEnv()
+	if *jiraURLFlag != "" {
+		jiraConfig.BaseURL = *jiraURLFlag
+	}
+	if *jiraFieldFlag != "" {
+		jiraConfig.StoryPointsField = *jiraFieldFlag
+	}
+	jiraClient, err := jira.NewClient(jiraConfig)
+	if err != nil && !errors.Is(err, jira.ErrNotConfigured) {
+		exit(ExitUsage, "invalid jira configuration: %s", err)
+	}
+
+	// peers behind NATs reserve a slot in a relay, by default the bootstrap
+	// servers, which can double as relays
+	if len(relayAddrs) == 0 {
+		relayAddrs = bootstrapAddrs
+	}
+	natConfig := nat.Config{
+		Relays:       relayAddrs.AddrInfos(),
+		RelayService: *relayFlag,
+	}
+
+	// load the key of this peer so it keeps the same ID across runs
+	// each running peer locks its key, the ones started without -identity
+	// take the next default key file free
+	var privKey crypto.PrivKey
+	identityPath := *identityFlag
+	if identityPath == "" {
+		privKey, identityPath, err = identity.OpenDefault()
+	} else {
+		privKey, err = identity.Open(identityPath)
+	}
+	switch {
+	case errors.Is(err, identity.ErrInUse) && *identityFlag != "":
+		exit(ExitUsage, "%s is used by another running peer, pass another file with -identity", identityPath)
+	case errors.Is(err, identity.ErrInUse):
+		exit(ExitUsage, "too many peers running on this machine, pass a key file with -identity")
+	case err != nil:
+		exit(ExitFailure, "can't load the identity of this peer from %s: %s", identityPath, err)
+	}
+
+	if code, err := checkListenAddr(*ipAddressFlag, *ipPortFlag); err != nil {
+		exit(code, "%s", err)
+	}
+
 	// create a new libp2p Host that listens on a random TCP port
-	h, err := libp2p.New(
-		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/%s/tcp/%s", *ipAddressFlag, *ipPortFlag)))
+	options := []libp2p.Option{
+		libp2p.Identity(privKey),
+		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/%s/tcp/%s", *ipAddressFlag, *ipPortFlag)),
+	}
+	options = append(options, nat.Options(natConfig)...)
+	h, err := libp2p.New(options...)
 	if err != nil {
-		panic(err)
+		exit(ExitNetwork, "can't create the peer: %s", err)
 	}
 
-	// view host details and addresses
-	log.Printf("host ID %s\n", h.ID().Pretty())
-	log.Printf("following are the assigned addresses\n")
-	for _, addr := range h.Addrs() {
-		log.Printf("%s\n", addr.String())
+	// every log entry tells which peer wrote it, libp2p uses "peer" for
+	// the remote one
+	logging.With("self", h.ID().Pretty())
+	logger.Infow("host started", "addrs", h.Addrs())
+
+	if serverMode {
+		// bootstrap servers only need the DHT, they don't join any room.
+		// this peer should run on cloud (with public ip address)
+		logger.Infow("running in server mode", "relay", *relayFlag)
+		_, err := discovery.NewDHT(ctx, h, bootstrapAddrs, true, events)
+		if err != nil {
+			exit(ExitNetwork, "can't start the DHT: %s", err)
+		}
+		fmt.Println("bootstrap server started! peers can join using:")
+		for _, addr := range h.Addrs() {
+			fmt.Printf("-bootstrap-addr %s/p2p/%s\n", addr, h.ID().Pretty())
+		}
+		select {}
 	}
-	log.Printf("\n")
+
+	logging.With("room", room)
 
 	// create a new PubSub service using the GossipSub router
 	ps, err := pubsub.NewGossipSub(ctx, h)
 	if err != nil {
-		panic(err)
-	}
-
-	// TODO: uncomment when handling public nodes discovery
-	// discoveryPeers := []multiaddr.Multiaddr{}
-	// if !serverMode {
-	// 	log.Println("running in normal host mode")
-	// 	// if bootstrapServerAddressFlag is nil
-	// 	// setup DHT with empty discovery peers
-	// 	// so this will be a discovery peer for others
-	// 	// this peer should run on cloud(with public ip address)
-	// 	multiAddr, err := multiaddr.NewMultiaddr(*bootstrapServerAddressFlag)
-	// 	if err != nil {
-	// 		panic(err)
-	// 	}
-	// 	discoveryPeers = append(discoveryPeers, multiAddr)
-	// } else {
-	// 	log.Println("running in server mode")
-	// }
-	// dht, err := discovery.NewDHT(ctx, h, discoveryPeers)
-	// if err != nil {
-	// 	panic(err)
-	// }
-
-	// // setup peer discovery
-	// go discovery.Discover(ctx, h, dht, room)
-
-	// setup local mDNS discovery
-	if err := setupDiscovery(h); err != nil {
-		panic(err)
+		exit(ExitNetwork, "can't start pubsub: %s", err)
 	}
 
 	// use the nickname from the cli flag, or a default if blank
@@ -95,26 +258,162 @@ func main() {
 		nick = defaultNick(h.ID())
 	}
 
-	// TODO: uncomment when handling public nodes discovery
-	// if serverMode {
-	// 	log.Println("bootstrap server started!")
-	// 	select {}
-	// }
+	// join the chat room, encrypted if it has a secret
+	codec, err := chatroom.ParseCodec(*codecFlag)
+	if err != nil {
+		exit(ExitUsage, "%s", err)
+	}
+	roomOptions := []chatroom.Option{
+		chatroom.WithCodec(codec),
+		chatroom.WithActivity(events),
+		chatroom.WithNetwork(h.Network()),
+	}
+	if *roomSecretFlag != "" {
+		roomOptions = append(roomOptions, chatroom.WithSecret(*roomSecretFlag))
+	}
+	cr, err := chatroom.JoinChatRoom(ctx, ps, h.ID(), nick, room, roomOptions...)
+	if err != nil {
+		exit(ExitNetwork, "can't join room %q: %s", room, err)
+	}
+
+	// setup peer discovery over the internet, using the room as rendezvous
+	if len(bootstrapAddrs) > 0 {
+		logger.Infow("joining the DHT", "bootstrap", bootstrapAddrs.String())
+		dht, err := discovery.NewDHT(ctx, h, bootstrapAddrs, false, events)
+		if err != nil {
+			exit(ExitNetwork, "can't connect to the bootstrap servers: %s", err)
+		}
+		go discovery.Discover(ctx, h, dht, cr.Rendezvous(), events)
+	}
+
+	// dial again the peers of the room we lose the connection to
+	go discovery.Redial(ctx, h, func() []peer.ID {
+		return cr.Presence.Recent(discovery.RedialWindow)
+	}, events)
 
-	// join the chat room
-	cr, err := chatroom.JoinChatRoom(ctx, ps, h.ID(), nick, room)
+	// setup local mDNS discovery
+	if err := setupDiscovery(h, events); err != nil {
+		exit(ExitNetwork, "can't start the local discovery: %s", err)
+	}
+
+	estimationSession := session.New(cr, cr.Presence, h.ID(), nick, initialDeck)
+
+	// the bot writes the events of the room to stdout, so it logs the
+	// notices the UI would show
+	notify := func(notice string) { logger.Info(notice) }
+	setConnectivity := func(status string) { logger.Infow("connectivity changed", "status", status) }
+	mode := "bot"
+	run := func() error {
+		return bot.New(cr, estimationSession, decks).Run(ctx, os.Stdin, os.Stdout)
+	}
+	switch {
+	case command == BotCommand:
+	case *webFlag != "":
+		// the browser only talks to this peer, which joins the room
+		webUI := web.New(estimationSession, room, decks)
+		if *allowRemoteFlag {
+			webUI.AllowRemote()
+		}
+		mode = "web UI"
+		notify, setConnectivity = webUI.Notify, webUI.SetConnectivity
+		run = func() error {
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go func() {
+				if err := estimationSession.Run(ctx, cr.Messages); err != nil {
+					notify(err.Error())
+				}
+			}()
+			logger.Infow("serving the web UI", "url", "http://"+api.LocalAddr(*webFlag))
+			err := webUI.ListenAndServe(ctx, *webFlag)
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+	default:
+		// draw the UI
+		estimationUI := ui.NewEstimationUI(cr, estimationSession, ui.Options{
+			Decks:    decks,
+			Jira:     jiraClient,
+			History:  historyStore,
+			Activity: events,
+		})
+		mode = "text UI"
+		notify, setConnectivity, run = estimationUI.Notify, estimationUI.SetConnectivity, estimationUI.Run
+	}
+
+	// integrations drive the room through the local API, next to the UI
+	if *apiFlag != "" {
+		apiServer := api.New(estimationSession, cr, decks)
+		if *allowRemoteFlag {
+			apiServer.AllowRemote()
+		}
+		go func() {
+			if err := apiServer.ListenAndServe(ctx, *apiFlag); err != nil {
+				notify(fmt.Sprintf("error serving the API: %s", err))
+			}
+		}()
+	}
+
+	// keep every round revealed in the history
+	rounds := make(chan history.Round, HistoryBufSize)
+	estimationSession.OnRoundCompleted(func(r session.RoundResult) {
+		rounds <- historyRound(room, r)
+	})
+	historyDone := make(chan struct{})
+	go func() {
+		defer close(historyDone)
+		for r := range rounds {
+			if err := historyStore.Add(r); err != nil {
+				notify(fmt.Sprintf("error saving history: %s", err))
+			}
+		}
+	}()
+
+	// show if we are directly reachable or relayed
+	statuses, err := nat.WatchStatus(ctx, h)
 	if err != nil {
-		panic(err)
+		exit(ExitFailure, "can't watch the connectivity of this peer: %s", err)
+	}
+	go func() {
+		for s := range statuses {
+			setConnectivity(string(s))
+		}
+	}()
+
+	runErr := run()
+
+	// save the last round before leaving
+	estimationSession.CompleteRound()
+	close(rounds)
+	<-historyDone
+
+	if runErr != nil {
+		exit(ExitFailure, "error running %s: %s", mode, runErr)
 	}
+}
 
-	// draw the UI
-	estimationUI := ui.NewEstimationUI(cr)
-	if err = estimationUI.Run(); err != nil {
-		printErr("error running text UI: %s", err)
+// historyRound converts a round of the session to be kept in the history.
+func historyRound(room string, r session.RoundResult) history.Round {
+	hr := history.Round{
+		Room:       room,
+		Story:      r.Story,
+		Round:      r.Round,
+		RevealedAt: r.RevealedAt,
+		Estimate:   r.Estimate,
 	}
+	for _, p := range r.Votes {
+		hr.Votes = append(hr.Votes, history.Vote{
+			PeerID: p.ID.Pretty(),
+			Nick:   p.Nick,
+			Vote:   p.Vote,
+		})
+	}
+	return hr
 }
 
-// printErr is like log.Printf, but writes to stderr.
+// printErr is like fmt.Printf, but writes to stderr.
 func printErr(m string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, m, args...)
 }
@@ -127,25 +426,25 @@ func defaultNick(p peer.ID) string {
 
 // discoveryNotifee gets notified when we find a new peer via mDNS discovery
 type discoveryNotifee struct {
-	h host.Host
+	h      host.Host
+	events *activity.Log
 }
 
 // HandlePeerFound connects to peers discovered via mDNS. Once they're connected,
 // the PubSub system will automatically start interacting with them if they also
 // support PubSub.
 func (n *discoveryNotifee) HandlePeerFound(pi peer.AddrInfo) {
-	//TODO - show new peer notif - log.Printf("discovered new peer %s\n", pi.ID.Pretty())
+	n.events.Add(activity.Network, "discovered new peer %s on the local network", pi.ID.ShortString())
 	err := n.h.Connect(context.Background(), pi)
 	if err != nil {
-		return
-		//TODO: show error - log.Printf("error connecting to peer %s: %s\n", pi.ID.Pretty(), err)
+		n.events.Add(activity.Error, "error connecting to peer %s: %s", pi.ID.ShortString(), err)
 	}
 }
 
 // setupDiscovery creates an mDNS discovery service and attaches it to the libp2p Host.
 // This lets us automatically discover peers on the same LAN and connect to them.
-func setupDiscovery(h host.Host) error {
+func setupDiscovery(h host.Host, events *activity.Log) error {
 	// setup mDNS discovery to find local peers
-	s := mdns.NewMdnsService(h, DiscoveryServiceTag, &discoveryNotifee{h: h})
+	s := mdns.NewMdnsService(h, DiscoveryServiceTag, &discoveryNotifee{h: h, events: events})
 	return s.Start()
 }
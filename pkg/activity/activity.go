@@ -0,0 +1,72 @@
+// Package activity collects what happens around the room for the user to
+// see: peers found and lost, connection errors and what the participants
+// do. The text UI can't share the terminal with the standard logger, so
+// the events are sent through a channel instead.
+package activity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/logging"
+)
+
+var logger = logging.Logger("activity")
+
+// BufSize is the number of events waiting to be shown. Newer events are
+// dropped while it is full, so a slow UI never blocks the network.
+const BufSize = 256
+
+// Kind groups the events.
+type Kind string
+
+const (
+	// Network events are about peers being found and connected.
+	Network Kind = "network"
+	// Room events are what the participants do.
+	Room Kind = "room"
+	// Error events are failures the user may want to know about.
+	Error Kind = "error"
+)
+
+// Event is something that happened.
+type Event struct {
+	Time time.Time
+	Kind Kind
+	Text string
+}
+
+// Log sends the events to whoever shows them. It is safe for concurrent
+// use. Events are always logged, a nil Log only logs them, for the modes
+// without the text UI.
+type Log struct {
+	events chan Event
+}
+
+// New creates a log, whose events must be read from Events.
+func New() *Log {
+	return &Log{events: make(chan Event, BufSize)}
+}
+
+// Add records an event, formatting the text like fmt.Sprintf.
+func (l *Log) Add(kind Kind, format string, args ...interface{}) {
+	text := fmt.Sprintf(format, args...)
+	if kind == Error {
+		logger.Warnw(text, "kind", kind)
+	} else {
+		logger.Infow(text, "kind", kind)
+	}
+	if l == nil {
+		return
+	}
+
+	select {
+	case l.events <- Event{Time: time.Now(), Kind: kind, Text: text}:
+	default:
+	}
+}
+
+// Events returns the channel the events are sent to.
+func (l *Log) Events() <-chan Event {
+	return l.events
+}
@@ -0,0 +1,376 @@
+// Package api serves a local JSON API to follow and drive the estimation
+// session, for integrations like editor plugins and scripts. It runs
+// alongside the text UI.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/control"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// UnixPrefix selects a Unix socket instead of TCP, e.g. unix:/tmp/estimator.sock.
+const UnixPrefix = "unix:"
+
+// ErrNotLoopback is returned when serving on an address other machines can
+// reach without allowing it.
+var ErrNotLoopback = errors.New("other machines can reach the address")
+
+// Watcher gives a copy of the messages of the room. It is implemented by
+// chatroom.ChatRoom.
+type Watcher interface {
+	Watch() (<-chan *chatroom.ChatMessage, func())
+}
+
+// Server is the API of an estimation session.
+type Server struct {
+	session     *session.EstimationSession
+	control     *control.Controller
+	watcher     Watcher
+	allowRemote bool
+}
+
+// Vote is the vote of a participant. The vote is only known after the
+// reveal, except for ourselves.
+type Vote struct {
+	Peer  peer.ID `json:"peer"`
+	Nick  string  `json:"nick"`
+	Voted bool    `json:"voted"`
+	Vote  string  `json:"vote,omitempty"`
+}
+
+// Votes of the current round.
+type Votes struct {
+	Revealed   bool                `json:"revealed"`
+	Round      int                 `json:"round"`
+	Votes      []Vote              `json:"votes"`
+	Statistics *session.Statistics `json:"statistics,omitempty"`
+}
+
+// Message is a message sent or received in the room, with the payload of
+// its type.
+type Message struct {
+	Time    time.Time                `json:"time"`
+	ID      string                   `json:"id,omitempty"`
+	Version int                      `json:"version"`
+	Type    chatroom.ChatMessageType `json:"type"`
+	From    peer.ID                  `json:"from"`
+	Nick    string                   `json:"nick"`
+	SentAt  time.Time                `json:"sent_at"`
+	Round   int                      `json:"round"`
+	Payload json.RawMessage          `json:"payload,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New creates the API for the session. The decks are the ones the room can
+// be switched to.
+func New(s *session.EstimationSession, w Watcher, decks []deck.Deck) *Server {
+	return &Server{
+		session: s,
+		control: control.New(s, decks),
+		watcher: w,
+	}
+}
+
+// ListenAndServe serves the API until the context is done. Addresses
+// other machines can reach are refused with ErrNotLoopback, unless
+// AllowRemote was called.
+func (srv *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if !srv.allowRemote {
+		if err := CheckLocal(addr); err != nil {
+			return err
+		}
+	}
+	l, err := Listen(addr)
+	if err != nil {
+		return err
+	}
+
+	handler := srv.Handler()
+	if !strings.HasPrefix(addr, UnixPrefix) {
+		handler = HostCheck(addr, handler)
+	}
+	hs := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		hs.Close()
+	}()
+
+	err = hs.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Listen listens on a TCP address or, with UnixPrefix, on a Unix socket
+// only the user can use. TCP addresses without a host only listen on
+// localhost.
+func Listen(addr string) (net.Listener, error) {
+	path := strings.TrimPrefix(addr, UnixPrefix)
+	if path == addr {
+		return net.Listen("tcp", LocalAddr(addr))
+	}
+
+	// the socket of a previous run is left behind if it didn't exit cleanly
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// LocalAddr makes addresses without a host listen on localhost.
+func LocalAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+// CheckLocal returns ErrNotLoopback if other machines can reach the
+// address, e.g. 0.0.0.0:8080. Unix sockets and addresses without a host
+// are local.
+func CheckLocal(addr string) error {
+	if strings.HasPrefix(addr, UnixPrefix) {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(LocalAddr(addr))
+	if err != nil {
+		return err
+	}
+	if !loopback(host) {
+		return ErrNotLoopback
+	}
+	return nil
+}
+
+// HostCheck rejects requests for host names other than localhost, so that
+// other sites can't reach the handler by pointing their names to our
+// address. When listening on a loopback address only loopback hosts are
+// accepted, otherwise the IP addresses of the machine are too.
+func HostCheck(addr string, next http.Handler) http.Handler {
+	if CheckLocal(addr) == nil {
+		return LocalOnly(next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := requestHost(r)
+		if host != "localhost" && net.ParseIP(host) == nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LocalOnly rejects requests for other hosts than localhost and the
+// loopback addresses, so that other sites can't reach the handler by
+// pointing their names to 127.0.0.1.
+func LocalOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loopback(requestHost(r)) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return r.Host
+	}
+	return host
+}
+
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// AllowRemote lets the API listen on addresses other machines can reach.
+func (srv *Server) AllowRemote() {
+	srv.allowRemote = true
+}
+
+// Handler returns the handler of the API.
+func (srv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", srv.get(func() interface{} { return srv.session.State() }))
+	mux.HandleFunc("/participants", srv.get(func() interface{} { return srv.session.State().Participants }))
+	mux.HandleFunc("/votes", srv.get(func() interface{} { return votes(srv.session.State()) }))
+	mux.HandleFunc("/description", srv.description)
+	mux.HandleFunc("/vote", srv.post(control.Vote, "card"))
+	mux.HandleFunc("/reveal", srv.post(control.Reveal, ""))
+	mux.HandleFunc("/clear", srv.post(control.Clear, ""))
+	mux.HandleFunc("/commands/", srv.commands)
+	mux.HandleFunc("/events", srv.events)
+	return mux
+}
+
+func votes(state session.State) Votes {
+	v := Votes{
+		Revealed:   state.Revealed,
+		Round:      state.StoryRound,
+		Votes:      []Vote{},
+		Statistics: state.Statistics,
+	}
+	for _, p := range state.Participants {
+		v.Votes = append(v.Votes, Vote{Peer: p.ID, Nick: p.Nick, Voted: p.Voted, Vote: p.Vote})
+	}
+	return v
+}
+
+func (srv *Server) description(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]string{"description": srv.session.State().Description})
+		return
+	}
+	srv.post(control.Description, "description")(w, r)
+}
+
+// commands runs any of the commands in the path, e.g. POST /commands/next,
+// with the argument in the arg field of the body.
+func (srv *Server) commands(w http.ResponseWriter, r *http.Request) {
+	command := strings.TrimPrefix(r.URL.Path, "/commands/")
+	srv.post(command, "arg")(w, r)
+}
+
+func (srv *Server) get(f func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		writeJSON(w, http.StatusOK, f())
+	}
+}
+
+// post runs a command with the argument in the field of the JSON body.
+func (srv *Server) post(command string, field string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		// other sites can't send JSON without the browser asking first, and
+		// browsers tell where the requests they send come from
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, errors.New("the content type must be application/json"))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+			writeError(w, http.StatusForbidden, errors.New("requests from other sites are forbidden"))
+			return
+		}
+
+		arg := ""
+		if field != "" {
+			body := map[string]string{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			arg = body[field]
+		}
+
+		err := srv.control.Exec(command, arg)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, control.ErrUnknownCommand):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, session.ErrRequested):
+			// the facilitator got the request, it's not a failure
+			writeError(w, http.StatusAccepted, err)
+		default:
+			writeError(w, http.StatusBadRequest, err)
+		}
+	}
+}
+
+// events streams the messages of the room as JSON lines.
+func (srv *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+
+	messages, stop := srv.watcher.Watch()
+	defer stop()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			err := enc.Encode(Message{
+				Time:    time.Now(),
+				ID:      msg.ID,
+				Version: msg.Version,
+				Type:    msg.MessageType,
+				From:    msg.From,
+				Nick:    msg.SenderNick,
+				SentAt:  msg.SentAt,
+				Round:   msg.Round,
+				Payload: msg.Payload,
+			})
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
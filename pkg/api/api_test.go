@@ -0,0 +1,133 @@
+package api
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/presence"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestCheckLocal(t *testing.T) {
+	tests := []struct {
+		addr string
+		want error
+	}{
+		{":7070", nil},
+		{"localhost:7070", nil},
+		{"127.0.0.1:7070", nil},
+		{"127.0.0.2:7070", nil},
+		{"[::1]:7070", nil},
+		{"unix:/tmp/estimator.sock", nil},
+		{"0.0.0.0:7070", ErrNotLoopback},
+		{"[::]:7070", ErrNotLoopback},
+		{"192.168.1.10:7070", ErrNotLoopback},
+		{"example.com:7070", ErrNotLoopback},
+	}
+	for _, tt := range tests {
+		if err := CheckLocal(tt.addr); !errors.Is(err, tt.want) {
+			t.Errorf("CheckLocal(%q) = %v, want %v", tt.addr, err, tt.want)
+		}
+	}
+	if err := CheckLocal("7070"); err == nil {
+		t.Error("CheckLocal() accepted an address without a port")
+	}
+}
+
+func TestHostCheck(t *testing.T) {
+	tests := []struct {
+		addr string
+		host string
+		want int
+	}{
+		{":7070", "localhost:7070", http.StatusOK},
+		{":7070", "127.0.0.1:7070", http.StatusOK},
+		{":7070", "[::1]:7070", http.StatusOK},
+		{":7070", "localhost", http.StatusOK},
+		{":7070", "192.168.1.10:7070", http.StatusForbidden},
+		{":7070", "evil.example.com:7070", http.StatusForbidden},
+		{"0.0.0.0:7070", "192.168.1.10:7070", http.StatusOK},
+		{"0.0.0.0:7070", "localhost:7070", http.StatusOK},
+		{"0.0.0.0:7070", "evil.example.com:7070", http.StatusForbidden},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/state", nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		HostCheck(tt.addr, ok).ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("listening on %s, request for %s = %d, want %d", tt.addr, tt.host, w.Code, tt.want)
+		}
+	}
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(chatroom.ChatMessageType, int, interface{}) error { return nil }
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := session.New(nopPublisher{}, presence.NewTracker(), id, "alice", deck.Default)
+	return New(s, nil, deck.Defaults())
+}
+
+func TestPost(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		origin      string
+		want        int
+	}{
+		{"json", "application/json", "", http.StatusNoContent},
+		{"json with charset", "application/json; charset=utf-8", "", http.StatusNoContent},
+		{"same origin", "application/json", "http://localhost:7070", http.StatusNoContent},
+		{"form", "application/x-www-form-urlencoded", "", http.StatusUnsupportedMediaType},
+		{"plain text", "text/plain", "", http.StatusUnsupportedMediaType},
+		{"no content type", "", "", http.StatusUnsupportedMediaType},
+		{"other origin", "application/json", "http://evil.example.com", http.StatusForbidden},
+		{"null origin", "application/json", "null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+
+			r := httptest.NewRequest(http.MethodPost, "/vote", strings.NewReader(`{"card": "5"}`))
+			r.Host = "localhost:7070"
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("POST /vote = %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+			voted := srv.session.State().Participants[0].Voted
+			if voted != (tt.want == http.StatusNoContent) {
+				t.Errorf("voted = %t after a %d", voted, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package backlog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrEmpty is returned when there are no stories to load.
+var ErrEmpty = errors.New("the backlog has no stories")
+
+// Story is an item of the backlog estimated by the room.
+type Story struct {
+	Key   string `json:"key,omitempty"`
+	Title string `json:"title"`
+	// Estimate is the card agreed by the room, empty until estimated.
+	Estimate string `json:"estimate,omitempty"`
+}
+
+func (s Story) String() string {
+	if s.Key == "" {
+		return s.Title
+	}
+	return s.Key + ": " + s.Title
+}
+
+// listMarker matches markdown list markers, including task list boxes.
+var listMarker = regexp.MustCompile(`^(?:[-*+]|[0-9]+[.)])(?:\s+|$)(?:\[[ xX]\](?:\s+|$))?`)
+
+// keyPattern matches issue keys like PROJ-123 at the start of a story.
+var keyPattern = regexp.MustCompile(`^\[?([A-Z][A-Z0-9]+-[0-9]+)\]?[:\s-]+`)
+
+// Load reads the stories from a file. The format is chosen by the
+// extension: .csv, .json, or text with a story per line for the others,
+// like markdown lists.
+func Load(path string) ([]Story, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var stories []Story
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		stories, err = parseCSV(data)
+	case ".json":
+		stories, err = parseJSON(data)
+	default:
+		stories = ParseText(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid backlog file %s: %w", path, err)
+	}
+	if len(stories) == 0 {
+		return nil, ErrEmpty
+	}
+	return stories, nil
+}
+
+// ParseText reads a story per line, ignoring list markers and markdown
+// headers. Lines starting with an issue key, like "PROJ-1: title", keep
+// the key apart.
+func ParseText(text string) []Story {
+	var stories []Story
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(listMarker.ReplaceAllString(line, ""))
+		if line == "" {
+			continue
+		}
+		stories = append(stories, newStory(line))
+	}
+	return stories
+}
+
+// parseCSV reads stories with a title column and, optionally, a key
+// column before it. A header row with "key" or "title" is skipped.
+func parseCSV(data []byte) ([]Story, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var stories []Story
+	for i, record := range records {
+		if i == 0 && isHeader(record) {
+			continue
+		}
+		switch len(record) {
+		case 0:
+			continue
+		case 1:
+			if strings.TrimSpace(record[0]) != "" {
+				stories = append(stories, newStory(strings.TrimSpace(record[0])))
+			}
+		default:
+			stories = append(stories, Story{
+				Key:   strings.TrimSpace(record[0]),
+				Title: strings.TrimSpace(record[1]),
+			})
+		}
+	}
+	return stories, nil
+}
+
+// parseJSON reads a list of stories, or a list of titles.
+func parseJSON(data []byte) ([]Story, error) {
+	var stories []Story
+	if err := json.Unmarshal(data, &stories); err == nil {
+		return stories, nil
+	}
+
+	// the stories decoded before failing are empty
+	stories = nil
+	var titles []string
+	if err := json.Unmarshal(data, &titles); err != nil {
+		return nil, err
+	}
+	for _, t := range titles {
+		stories = append(stories, newStory(t))
+	}
+	return stories, nil
+}
+
+func newStory(line string) Story {
+	m := keyPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Story{Title: line}
+	}
+	return Story{Key: m[1], Title: strings.TrimSpace(line[len(m[0]):])}
+}
+
+func isHeader(record []string) bool {
+	for _, field := range record {
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "key", "title", "summary", "story":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package backlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Story
+	}{
+		{
+			name: "a story per line",
+			text: "login page\n\n  logout  \n",
+			want: []Story{{Title: "login page"}, {Title: "logout"}},
+		},
+		{
+			name: "markdown lists",
+			text: "# Sprint 12\n- login page\n* logout\n+ search\n1. reset password\n2) profile\n",
+			want: []Story{{Title: "login page"}, {Title: "logout"}, {Title: "search"}, {Title: "reset password"}, {Title: "profile"}},
+		},
+		{
+			name: "task boxes",
+			text: "- [ ] login page\n- [x] logout\n- [X] search\n- []",
+			want: []Story{{Title: "login page"}, {Title: "logout"}, {Title: "search"}, {Title: "[]"}},
+		},
+		{
+			name: "keys",
+			text: "PROJ-1: login page\n- [PROJ-2] logout\nPROJ-3 - search\nAB2-45 reset password\n",
+			want: []Story{
+				{Key: "PROJ-1", Title: "login page"},
+				{Key: "PROJ-2", Title: "logout"},
+				{Key: "PROJ-3", Title: "search"},
+				{Key: "AB2-45", Title: "reset password"},
+			},
+		},
+		{
+			name: "not keys",
+			text: "Proj-1: lowercase\nPROJ-2\nP-3: one letter\nPROJ-4:no space is fine",
+			want: []Story{
+				{Title: "Proj-1: lowercase"},
+				{Title: "PROJ-2"},
+				{Title: "P-3: one letter"},
+				{Key: "PROJ-4", Title: "no space is fine"},
+			},
+		},
+		{
+			name: "only headers and markers",
+			text: "# Sprint\n- \n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseText(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseText() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Story
+		wantErr bool
+	}{
+		{
+			name: "key and title",
+			data: "PROJ-1,login page\nPROJ-2, logout \n",
+			want: []Story{{Key: "PROJ-1", Title: "login page"}, {Key: "PROJ-2", Title: "logout"}},
+		},
+		{
+			name: "header",
+			data: "Key,Summary\nPROJ-1,login page\n",
+			want: []Story{{Key: "PROJ-1", Title: "login page"}},
+		},
+		{
+			name: "title header",
+			data: "title\nlogin page\nPROJ-2: logout\n",
+			want: []Story{{Title: "login page"}, {Key: "PROJ-2", Title: "logout"}},
+		},
+		{
+			name: "only the first row can be a header",
+			data: "PROJ-1,login page\nkey,title\n",
+			want: []Story{{Key: "PROJ-1", Title: "login page"}, {Key: "key", Title: "title"}},
+		},
+		{
+			name: "extra columns and blank titles",
+			data: "PROJ-1,login page,5\n\" \"\n",
+			want: []Story{{Key: "PROJ-1", Title: "login page"}},
+		},
+		{
+			name:    "malformed",
+			data:    "\"login page\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSV([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCSV() error = %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Story
+		wantErr bool
+	}{
+		{
+			name: "stories",
+			data: `[{"key": "PROJ-1", "title": "login page"}, {"title": "logout", "estimate": "3"}]`,
+			want: []Story{{Key: "PROJ-1", Title: "login page"}, {Title: "logout", Estimate: "3"}},
+		},
+		{
+			name: "titles",
+			data: `["PROJ-1: login page", "logout"]`,
+			want: []Story{{Key: "PROJ-1", Title: "login page"}, {Title: "logout"}},
+		},
+		{
+			name: "empty",
+			data: `[]`,
+			want: []Story{},
+		},
+		{
+			name:    "not a list",
+			data:    `{"title": "login page"}`,
+			wantErr: true,
+		},
+		{
+			name:    "numbers",
+			data:    `[1, 2]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSON([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJSON() error = %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	login := []Story{{Key: "PROJ-1", Title: "login page"}}
+	tests := []struct {
+		file    string
+		data    string
+		want    []Story
+		wantErr error
+	}{
+		{file: "backlog.csv", data: "PROJ-1,login page\n", want: login},
+		{file: "backlog.JSON", data: `["PROJ-1: login page"]`, want: login},
+		{file: "backlog.md", data: "- [ ] PROJ-1: login page\n", want: login},
+		{file: "backlog", data: "PROJ-1: login page\n", want: login},
+		{file: "empty.md", data: "# Sprint\n", wantErr: ErrEmpty},
+		{file: "empty.json", data: "[]", wantErr: ErrEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Load(path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backlog.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || errors.Is(err, ErrEmpty) {
+		t.Errorf("Load() error = %v, want an invalid file error", err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() error = %v, want os.ErrNotExist", err)
+	}
+}
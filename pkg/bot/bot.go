@@ -0,0 +1,135 @@
+// Package bot joins an estimation room without the text UI. It reads
+// commands from a reader, one per line, and writes the events of the room
+// as JSON lines, for scripts, tests with many peers and recorder nodes.
+package bot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/control"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+)
+
+// PollInterval is how often the bot looks for changes in the room.
+const PollInterval = 100 * time.Millisecond
+
+// Bot takes part in an estimation session.
+type Bot struct {
+	cr      *chatroom.ChatRoom
+	session *session.EstimationSession
+	control *control.Controller
+
+	out   *json.Encoder
+	state session.State
+}
+
+// Reply is written after each command.
+type Reply struct {
+	Type    string         `json:"type"`
+	Time    time.Time      `json:"time"`
+	Command string         `json:"command"`
+	Error   string         `json:"error,omitempty"`
+	State   *session.State `json:"state,omitempty"`
+}
+
+// Reply types.
+const (
+	ReplyOK    = "ok"
+	ReplyError = "error"
+)
+
+// New creates a bot for the session. The decks are the ones the bot can
+// switch the room to.
+func New(cr *chatroom.ChatRoom, s *session.EstimationSession, decks []deck.Deck) *Bot {
+	return &Bot{
+		cr:      cr,
+		session: s,
+		control: control.New(s, decks),
+	}
+}
+
+// Run reads the commands from in and writes the events to out until the
+// context is done, in is closed or the quit command is read.
+func (b *Bot) Run(ctx context.Context, in io.Reader, out io.Writer) error {
+	b.out = json.NewEncoder(out)
+	b.state = b.session.State()
+
+	commands := make(chan string)
+	go func() {
+		defer close(commands)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case commands <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- b.session.Run(ctx, b.cr.Messages)
+	}()
+
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-done:
+			return err
+		case <-ticker.C:
+		case line, ok := <-commands:
+			if !ok {
+				return nil
+			}
+			quit, err := b.exec(line)
+			if err != nil {
+				return err
+			}
+			if quit {
+				return nil
+			}
+		}
+		if err := b.emitChanges(); err != nil {
+			return err
+		}
+	}
+}
+
+// emitChanges writes the events since the last state written.
+func (b *Bot) emitChanges() error {
+	next := b.session.State()
+	for _, e := range session.Changes(b.state, next) {
+		if err := b.out.Encode(e); err != nil {
+			return err
+		}
+	}
+	b.state = next
+	return nil
+}
+
+func (b *Bot) reply(command string, err error, state *session.State) error {
+	r := Reply{
+		Type:    ReplyOK,
+		Time:    time.Now(),
+		Command: command,
+		State:   state,
+	}
+	if err != nil {
+		r.Type = ReplyError
+		r.Error = err.Error()
+	}
+	return b.out.Encode(r)
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/renato0307/p2p-estimator/pkg/control"
+)
+
+// Usage lists the commands the bot understands.
+const Usage = `commands:
+  vote <card>           vote with a card of the deck
+  reveal                show the votes
+  clear                 clear the votes
+  revote                keep the votes revealed and vote again
+  description <text>    set what is being estimated
+  deck <name>           change the deck
+  backlog <file>        load the stories of a file
+  next|previous|skip    move through the backlog
+  handover <nick>       give the facilitator role to someone
+  state                 write the state of the room
+  quit                  leave the room`
+
+// exec runs a command line. Errors of the command are written as replies,
+// only errors writing them are returned.
+func (b *Bot) exec(line string) (quit bool, err error) {
+	command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+
+	switch command {
+	case "":
+		return false, nil
+	case "quit":
+		return true, b.reply(command, nil, nil)
+	case "state":
+		s := b.session.State()
+		return false, b.reply(command, nil, &s)
+	}
+
+	cmdErr := b.control.Exec(command, arg)
+	if errors.Is(cmdErr, control.ErrUnknownCommand) {
+		cmdErr = errors.New(cmdErr.Error() + "\n" + Usage)
+	}
+	return false, b.reply(command, cmdErr, nil)
+}
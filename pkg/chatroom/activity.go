@@ -0,0 +1,59 @@
+package chatroom
+
+import (
+	"fmt"
+
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+)
+
+// record adds an event to the activity log, if the room has one.
+func (cr *ChatRoom) record(kind activity.Kind, format string, args ...interface{}) {
+	if cr.activity == nil {
+		return
+	}
+	cr.activity.Add(kind, format, args...)
+}
+
+// recordMessage adds what the sender of a message did to the activity log.
+// Messages that only keep the peers in sync aren't recorded.
+func (cr *ChatRoom) recordMessage(who string, m *ChatMessage) {
+	if text, ok := describe(who, m); ok {
+		cr.record(activity.Room, "%s", text)
+	}
+}
+
+// describe tells what a message means to the user.
+func describe(who string, m *ChatMessage) (string, bool) {
+	switch m.MessageType {
+	case SendVote:
+		return who + " voted", true
+	case ShowVotes:
+		return who + " revealed the votes", true
+	case ClearVotes:
+		return who + " cleared the votes", true
+	case Revote:
+		return who + " started another round of votes", true
+	case SetDescription:
+		var p DescriptionPayload
+		if err := m.Decode(&p); err != nil {
+			return "", false
+		}
+		if p.Description == "" {
+			return who + " cleared the description", true
+		}
+		return fmt.Sprintf("%s changed the description to %q", who, p.Description), true
+	case SetDeck:
+		return who + " changed the deck", true
+	case SetBacklog:
+		return who + " loaded a backlog", true
+	case MoveStory:
+		return who + " moved to another story", true
+	case RequestControl:
+		var p RequestControlPayload
+		if err := m.Decode(&p); err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%s asked the facilitator to %s", who, p.Action), true
+	}
+	return "", false
+}
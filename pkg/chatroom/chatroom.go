@@ -3,8 +3,13 @@ package chatroom
 import (
 	"context"
 	"encoding/json"
+	"sync"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+	"github.com/renato0307/p2p-estimator/pkg/presence"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 )
@@ -19,14 +24,46 @@ type ChatRoom struct {
 	// Messages is a channel of messages received from other peers in the chat room
 	Messages chan *ChatMessage
 
-	ctx   context.Context
-	ps    *pubsub.PubSub
-	topic *pubsub.Topic
+	ctx       context.Context
+	ps        *pubsub.PubSub
+	topic     *pubsub.Topic
+	topicName string
+
+	// the subscription is replaced when it fails
+	subMu sync.Mutex
 	sub   *pubsub.Subscription
 
+	// connection state, with the messages published while reconnecting
+	connMu        sync.Mutex
+	reconnecting  bool
+	reconnections int
+	outbox        []*ChatMessage
+	flushing      bool
+	// round is the round of the last message received, the queued
+	// messages of older rounds are dropped
+	round int
+	// network tells if we are online, if set
+	network network.Network
+
+	key *roomKey
+	// preferredCodec is the codec chosen by the user, CodecAuto by default
+	preferredCodec Codec
+
+	// activity records what happens in the room, if set
+	activity *activity.Log
+
+	watchMu  sync.Mutex
+	watchers map[chan *ChatMessage]struct{}
+
+	versionsMu sync.Mutex
+	versions   map[peer.ID]int
+
 	RoomName string
 	Self     peer.ID
 	Nick     string
+
+	// Presence tracks the peers in the room.
+	Presence *presence.Tracker
 }
 
 type ChatMessageType string
@@ -35,90 +72,251 @@ const (
 	Heartbeat      ChatMessageType = "heartbeat"
 	SetDescription ChatMessageType = "set-description"
 	SendVote       ChatMessageType = "send-vote"
+	RevealVote     ChatMessageType = "reveal-vote"
 	ClearVotes     ChatMessageType = "clear-votes"
 	ShowVotes      ChatMessageType = "show-votes"
+	RequestState   ChatMessageType = "request-state"
+	SendState      ChatMessageType = "send-state"
+	SetDeck        ChatMessageType = "set-deck"
+	SetBacklog     ChatMessageType = "set-backlog"
+	MoveStory      ChatMessageType = "move-story"
+	SetFacilitator ChatMessageType = "set-facilitator"
+	RequestControl ChatMessageType = "request-control"
+	Revote         ChatMessageType = "revote"
 )
 
-// ChatMessage gets converted to/from JSON and sent in the body of pubsub messages.
-type ChatMessage struct {
-	MessageType ChatMessageType
-	Message     string
-	SenderID    string
-	SenderNick  string
-}
-
 // JoinChatRoom tries to subscribe to the PubSub topic for the room name, returning
 // a ChatRoom on success.
-func JoinChatRoom(ctx context.Context, ps *pubsub.PubSub, selfID peer.ID, nickname string, roomName string) (*ChatRoom, error) {
+func JoinChatRoom(ctx context.Context, ps *pubsub.PubSub, selfID peer.ID, nickname string, roomName string, opts ...Option) (*ChatRoom, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cr := &ChatRoom{
+		ctx:       ctx,
+		ps:        ps,
+		topicName: topicName(roomName),
+		Self:      selfID,
+		Nick:      nickname,
+		RoomName:  roomName,
+		Messages:  make(chan *ChatMessage, ChatRoomBufSize),
+		Presence:  presence.NewTracker(),
+
+		preferredCodec: CodecAuto,
+		activity:       o.activity,
+		network:        o.network,
+	}
+	if o.codec != "" {
+		cr.preferredCodec = o.codec
+	}
+
+	// protected rooms use a topic derived from the secret
+	if o.secret != "" {
+		key, err := deriveRoomKey(roomName, o.secret)
+		if err != nil {
+			return nil, err
+		}
+		cr.key = key
+		cr.topicName = key.topic
+	}
+
+	// reject messages whose claimed sender isn't the peer who signed them
+	err := ps.RegisterTopicValidator(cr.topicName, cr.validateSender)
+	if err != nil {
+		return nil, err
+	}
+
 	// join the pubsub topic
-	topic, err := ps.Join(topicName(roomName))
+	cr.topic, err = ps.Join(cr.topicName)
 	if err != nil {
 		return nil, err
 	}
 
 	// and subscribe to it
-	sub, err := topic.Subscribe()
+	cr.sub, err = cr.topic.Subscribe()
 	if err != nil {
 		return nil, err
 	}
 
-	cr := &ChatRoom{
-		ctx:      ctx,
-		ps:       ps,
-		topic:    topic,
-		sub:      sub,
-		Self:     selfID,
-		Nick:     nickname,
-		RoomName: roomName,
-		Messages: make(chan *ChatMessage, ChatRoomBufSize),
+	// follow the peers joining and leaving the topic
+	events, err := cr.topic.EventHandler()
+	if err != nil {
+		return nil, err
 	}
 
 	// start reading messages from the subscription in a loop
 	go cr.readLoop()
+	go cr.presenceLoop(events)
+	go cr.connectionLoop()
 	return cr, nil
 }
 
-// Publish sends a message to the pubsub topic.
-func (cr *ChatRoom) Publish(messageType ChatMessageType, message string) error {
+// Publish sends a message to the pubsub topic. The payload, which can be
+// nil, is encoded as JSON. While reconnecting, and until the messages
+// queued meanwhile are sent, the message is queued so that it doesn't
+// overtake them.
+func (cr *ChatRoom) Publish(messageType ChatMessageType, round int, payload interface{}) error {
 	m := ChatMessage{
+		Version:     Version,
+		ID:          newMessageID(),
 		MessageType: messageType,
-		Message:     message,
 		SenderID:    cr.Self.Pretty(),
 		SenderNick:  cr.Nick,
+		SentAt:      time.Now().UTC(),
+		Round:       round,
 	}
-	msgBytes, err := json.Marshal(m)
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		m.Payload = data
+	}
+	if queued, err := cr.queue(&m); queued {
+		return err
+	}
+	msgBytes, err := cr.marshal(&m)
 	if err != nil {
 		return err
 	}
-	return cr.topic.Publish(cr.ctx, msgBytes)
+	// the network may be gone, we only know after trying
+	if err := cr.publish(&m, msgBytes); err != nil {
+		cr.setReconnecting(true)
+		_, err := cr.queue(&m)
+		return err
+	}
+	return nil
+}
+
+// publish sends an encoded message to the topic.
+func (cr *ChatRoom) publish(m *ChatMessage, msgBytes []byte) error {
+	if err := cr.topic.Publish(cr.ctx, msgBytes); err != nil {
+		return err
+	}
+	m.From = cr.Self
+	cr.recordMessage("you", m)
+	cr.notifyWatchers(m)
+	return nil
 }
 
 func (cr *ChatRoom) ListPeers() []peer.ID {
-	return cr.ps.ListPeers(topicName(cr.RoomName))
+	return cr.ps.ListPeers(cr.topicName)
+}
+
+// Rendezvous returns the string peers of the room use to find each other.
+// It doesn't reveal the name of protected rooms.
+func (cr *ChatRoom) Rendezvous() string {
+	return cr.topicName
 }
 
 // readLoop pulls messages from the pubsub topic and pushes them onto the Messages channel.
 func (cr *ChatRoom) readLoop() {
 	for {
-		msg, err := cr.sub.Next(cr.ctx)
+		cr.subMu.Lock()
+		sub := cr.sub
+		cr.subMu.Unlock()
+
+		msg, err := sub.Next(cr.ctx)
 		if err != nil {
+			// the subscription can fail when the network drops, we only
+			// stop when leaving the room
+			if cr.ctx.Err() == nil && cr.resubscribe() {
+				continue
+			}
 			close(cr.Messages)
 			return
 		}
-		// only forward messages delivered by others
-		if msg.ReceivedFrom == cr.Self {
+		// only forward messages sent by others
+		if msg.GetFrom() == cr.Self {
 			continue
 		}
-		cm := new(ChatMessage)
-		err = json.Unmarshal(msg.Data, cm)
-		if err != nil {
+		// messages were decoded by the validator, the ones that couldn't
+		// be decrypted were silently dropped
+		cm, ok := msg.ValidatorData.(*ChatMessage)
+		if !ok {
 			continue
 		}
+		cm.From = msg.GetFrom()
+		cr.seenVersion(cm.From, cm.Version)
+		if p, ok := cr.Presence.Peer(cm.From); !ok || p.Status == presence.Left {
+			cr.record(activity.Room, "%s joined the room", cm.SenderNick)
+		}
+		cr.Presence.Seen(cm.From, cm.SenderNick)
+		// messages of newer peers we don't understand are ignored, but they
+		// were still relayed to the others by the validator
+		if !knownTypes[cm.MessageType] {
+			continue
+		}
+		cr.connMu.Lock()
+		cr.round = cm.Round
+		cr.connMu.Unlock()
+		cr.recordMessage(cm.SenderNick, cm)
+		cr.notifyWatchers(cm)
 		// send valid messages onto the Messages channel
 		cr.Messages <- cm
 	}
 }
 
+// presenceLoop tracks the peers that join and leave the pubsub topic.
+func (cr *ChatRoom) presenceLoop(events *pubsub.TopicEventHandler) {
+	defer events.Cancel()
+	for {
+		ev, err := events.NextPeerEvent(cr.ctx)
+		if err != nil {
+			return
+		}
+		switch ev.Type {
+		case pubsub.PeerJoin:
+			cr.Presence.Joined(ev.Peer)
+		case pubsub.PeerLeave:
+			if p, ok := cr.Presence.Peer(ev.Peer); ok && p.Status != presence.Left {
+				cr.record(activity.Room, "%s left the room", p.Nick)
+			}
+			cr.Presence.Left(ev.Peer)
+		}
+	}
+}
+
+// validateSender checks the SenderID claimed in the message against the
+// originator of the pubsub message, which is authenticated by its signature.
+func (cr *ChatRoom) validateSender(ctx context.Context, _ peer.ID, msg *pubsub.Message) bool {
+	cm, err := cr.unmarshal(msg.Data)
+	if err != nil {
+		return false
+	}
+	sender, err := peer.Decode(cm.SenderID)
+	if err != nil || sender != msg.GetFrom() {
+		return false
+	}
+	msg.ValidatorData = cm
+	return true
+}
+
+// marshal encodes the message, encrypting it in protected rooms.
+func (cr *ChatRoom) marshal(m *ChatMessage) ([]byte, error) {
+	data, err := encode(m, cr.codec())
+	if err != nil {
+		return nil, err
+	}
+	if cr.key == nil {
+		return data, nil
+	}
+	return cr.key.seal(data)
+}
+
+// unmarshal decodes a message encoded with marshal.
+func (cr *ChatRoom) unmarshal(data []byte) (*ChatMessage, error) {
+	if cr.key != nil {
+		var err error
+		data, err = cr.key.open(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return decode(data)
+}
+
 func topicName(roomName string) string {
 	return "chat-room:" + roomName
 }
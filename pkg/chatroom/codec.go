@@ -0,0 +1,194 @@
+package chatroom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Codec is how messages are encoded on the wire.
+type Codec string
+
+const (
+	// CodecAuto uses protobuf when every peer in the room can read it, and
+	// JSON otherwise.
+	CodecAuto Codec = "auto"
+	// CodecJSON is the encoding of every version.
+	CodecJSON Codec = "json"
+	// CodecProtobuf only encodes the envelope of the messages with
+	// protobuf, the payload stays JSON. It is smaller and faster for the
+	// messages with little or no payload, like heartbeats, but only read
+	// since ProtobufVersion.
+	CodecProtobuf Codec = "protobuf"
+)
+
+// ProtobufVersion is the first protocol version that reads protobuf.
+const ProtobufVersion = 3
+
+// protobufPrefix starts the messages encoded with protobuf. JSON messages
+// start with '{'.
+const protobufPrefix = 0x01
+
+// ErrInvalidProtobuf is returned when decoding a malformed message.
+var ErrInvalidProtobuf = errors.New("invalid protobuf message")
+
+// ParseCodec parses the name of a codec.
+func ParseCodec(name string) (Codec, error) {
+	switch c := Codec(name); c {
+	case CodecAuto, CodecJSON, CodecProtobuf:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown codec %q, use %s, %s or %s", name, CodecAuto, CodecJSON, CodecProtobuf)
+}
+
+// Fields of the protobuf encoding of ChatMessage, which is:
+//
+//	message ChatMessage {
+//	  uint32 version = 1;
+//	  string id = 2;
+//	  string type = 3;
+//	  string sender_id = 4;
+//	  string sender_nick = 5;
+//	  int64 sent_at_unix_nano = 6;
+//	  int64 round = 7;
+//	  bytes payload = 8; // the JSON of the typed payload
+//	}
+const (
+	fieldVersion protowire.Number = iota + 1
+	fieldID
+	fieldType
+	fieldSenderID
+	fieldSenderNick
+	fieldSentAt
+	fieldRound
+	fieldPayload
+)
+
+// codec returns the codec to use for the next message. With CodecAuto it
+// is protobuf only once we heard from every peer in the room and all of
+// them read it.
+func (cr *ChatRoom) codec() Codec {
+	if cr.preferredCodec != CodecAuto {
+		return cr.preferredCodec
+	}
+
+	peers := cr.ListPeers()
+	if len(peers) == 0 {
+		return CodecJSON
+	}
+
+	cr.versionsMu.Lock()
+	defer cr.versionsMu.Unlock()
+	for _, id := range peers {
+		if v, ok := cr.versions[id]; !ok || v < ProtobufVersion {
+			return CodecJSON
+		}
+	}
+	return CodecProtobuf
+}
+
+// encode encodes the message with the codec.
+func encode(m *ChatMessage, c Codec) ([]byte, error) {
+	if c != CodecProtobuf {
+		return json.Marshal(m)
+	}
+
+	b := []byte{protobufPrefix}
+	b = appendVarint(b, fieldVersion, uint64(m.Version))
+	b = appendString(b, fieldID, m.ID)
+	b = appendString(b, fieldType, string(m.MessageType))
+	b = appendString(b, fieldSenderID, m.SenderID)
+	b = appendString(b, fieldSenderNick, m.SenderNick)
+	if !m.SentAt.IsZero() {
+		b = appendVarint(b, fieldSentAt, uint64(m.SentAt.UnixNano()))
+	}
+	b = appendVarint(b, fieldRound, uint64(m.Round))
+	if len(m.Payload) > 0 {
+		b = protowire.AppendTag(b, fieldPayload, protowire.BytesType)
+		b = protowire.AppendBytes(b, m.Payload)
+	}
+	return b, nil
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// decodeProtobuf decodes a message encoded with protobuf, without the
+// prefix. Unknown fields, from newer versions, are skipped.
+func decodeProtobuf(b []byte) (*ChatMessage, error) {
+	cm := new(ChatMessage)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, ErrInvalidProtobuf
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.VarintType && isVarintField(num):
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, ErrInvalidProtobuf
+			}
+			b = b[n:]
+			switch num {
+			case fieldVersion:
+				cm.Version = int(v)
+			case fieldSentAt:
+				cm.SentAt = time.Unix(0, int64(v)).UTC()
+			case fieldRound:
+				cm.Round = int(int64(v))
+			}
+		case typ == protowire.BytesType && isBytesField(num):
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, ErrInvalidProtobuf
+			}
+			b = b[n:]
+			switch num {
+			case fieldID:
+				cm.ID = string(v)
+			case fieldType:
+				cm.MessageType = ChatMessageType(v)
+			case fieldSenderID:
+				cm.SenderID = string(v)
+			case fieldSenderNick:
+				cm.SenderNick = string(v)
+			case fieldPayload:
+				cm.Payload = append(json.RawMessage(nil), v...)
+			}
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return nil, ErrInvalidProtobuf
+			}
+			b = b[n:]
+		}
+	}
+	return cm, nil
+}
+
+func isVarintField(num protowire.Number) bool {
+	return num == fieldVersion || num == fieldSentAt || num == fieldRound
+}
+
+func isBytesField(num protowire.Number) bool {
+	return num == fieldID || num == fieldType || num == fieldSenderID ||
+		num == fieldSenderNick || num == fieldPayload
+}
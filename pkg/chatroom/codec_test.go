@@ -0,0 +1,118 @@
+package chatroom
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestProtobufRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *ChatMessage
+	}{
+		{"vote", vote},
+		{"negative round", &ChatMessage{Version: Version, MessageType: ClearVotes, SenderID: vote.SenderID, Round: -2}},
+		{"zero sent at", &ChatMessage{Version: Version, MessageType: Heartbeat, SenderID: vote.SenderID}},
+		{
+			"sent at with nanoseconds",
+			&ChatMessage{Version: Version, MessageType: Heartbeat, SentAt: time.Date(2022, 12, 1, 10, 0, 0, 123456789, time.UTC)},
+		},
+		{"empty", &ChatMessage{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := encode(tt.msg, CodecProtobuf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.msg) {
+				t.Errorf("decode(encode()) = %+v, want %+v", got, tt.msg)
+			}
+		})
+	}
+}
+
+func TestProtobufUnknownFields(t *testing.T) {
+	data, err := encode(vote, CodecProtobuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fields of newer versions, and known fields with another type
+	data = protowire.AppendTag(data, 20, protowire.VarintType)
+	data = protowire.AppendVarint(data, 42)
+	data = protowire.AppendTag(data, 21, protowire.BytesType)
+	data = protowire.AppendString(data, "unknown")
+	data = protowire.AppendTag(data, 22, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 7)
+	data = protowire.AppendTag(data, 23, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 7)
+	data = protowire.AppendTag(data, fieldRound, protowire.BytesType)
+	data = protowire.AppendString(data, "not a round")
+
+	got, err := decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, vote) {
+		t.Errorf("decode() = %+v, want %+v", got, vote)
+	}
+}
+
+func TestProtobufMalformed(t *testing.T) {
+	data, err := encode(vote, CodecProtobuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range [][]byte{data[:len(data)-1], data[:4], {protobufPrefix, 0xff}} {
+		if _, err := decode(b); err != ErrInvalidProtobuf {
+			t.Errorf("decode(%q) = %v, want ErrInvalidProtobuf", b, err)
+		}
+	}
+}
+
+var heartbeat = &ChatMessage{
+	Version:     Version,
+	ID:          "5f2b1c9a0d3e4f67",
+	MessageType: Heartbeat,
+	SenderID:    vote.SenderID,
+	SenderNick:  "alice",
+	SentAt:      vote.SentAt,
+	Round:       3,
+}
+
+func benchmarkEncode(b *testing.B, c Codec) {
+	data, _ := encode(heartbeat, c)
+	b.ReportMetric(float64(len(data)), "bytes/msg")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := encode(heartbeat, c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, c Codec) {
+	data, err := encode(heartbeat, c)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeJSON(b *testing.B)     { benchmarkEncode(b, CodecJSON) }
+func BenchmarkEncodeProtobuf(b *testing.B) { benchmarkEncode(b, CodecProtobuf) }
+func BenchmarkDecodeJSON(b *testing.B)     { benchmarkDecode(b, CodecJSON) }
+func BenchmarkDecodeProtobuf(b *testing.B) { benchmarkDecode(b, CodecProtobuf) }
@@ -0,0 +1,50 @@
+package chatroom
+
+import (
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// Option configures how to join a chat room.
+type Option func(*options)
+
+type options struct {
+	secret   string
+	codec    Codec
+	activity *activity.Log
+	network  network.Network
+}
+
+// WithSecret protects the room with a passphrase. Messages are encrypted
+// with a key derived from the room name and the passphrase, and only peers
+// knowing both can join the room.
+func WithSecret(secret string) Option {
+	return func(o *options) {
+		o.secret = secret
+	}
+}
+
+// WithCodec sets how messages are encoded. By default it is CodecAuto.
+func WithCodec(c Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithActivity records in the log who joins and leaves the room, what they
+// do and the connection problems.
+func WithActivity(l *activity.Log) Option {
+	return func(o *options) {
+		o.activity = l
+	}
+}
+
+// WithNetwork follows the connections of the host, to tell when we are
+// offline and must queue the messages until we reconnect. Without it only
+// the failures to subscribe and publish are noticed.
+func WithNetwork(n network.Network) Option {
+	return func(o *options) {
+		o.network = n
+	}
+}
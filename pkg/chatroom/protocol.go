@@ -0,0 +1,143 @@
+package chatroom
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Version of the protocol spoken by this peer. Version 2 is the envelope
+// with a typed payload and version 3 adds the protobuf codec. Newer
+// versions only add fields and message types, so their messages are read
+// ignoring what we don't know. The flat messages of the first releases,
+// without a version, aren't understood: they sent the votes in plain text.
+const Version = 3
+
+// ErrUnsupportedVersion is returned when decoding a message without a
+// version.
+var ErrUnsupportedVersion = errors.New("message of an unsupported protocol version")
+
+// ChatMessage gets converted to/from JSON and sent in the body of pubsub
+// messages. It is the envelope of every message sent in the room, the
+// payload is a JSON object whose type depends on the message type.
+type ChatMessage struct {
+	Version     int             `json:"version"`
+	ID          string          `json:"id"`
+	MessageType ChatMessageType `json:"type"`
+	SenderID    string          `json:"sender_id"`
+	SenderNick  string          `json:"sender_nick"`
+	SentAt      time.Time       `json:"sent_at"`
+	// Round is the round of votes of the room when the message was sent,
+	// or the new round for messages that start one.
+	Round   int             `json:"round"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	// From is the peer that signed the pubsub message. It is set on delivery
+	// and must be used instead of the self-reported SenderID.
+	From peer.ID `json:"-"`
+}
+
+// Decode decodes the payload of the message into v.
+func (m *ChatMessage) Decode(v interface{}) error {
+	if len(m.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(m.Payload, v)
+}
+
+// Payloads of the message types with a single value. The other message
+// types carry the state they change, e.g. the deck.
+type (
+	DescriptionPayload struct {
+		Description string `json:"description"`
+	}
+	VotePayload struct {
+		Commitment string `json:"commitment"`
+	}
+	OpeningPayload struct {
+		Opening string `json:"opening"`
+	}
+	RequestControlPayload struct {
+		Action string `json:"action"`
+	}
+)
+
+// knownTypes are the message types of this version. Messages of other
+// types come from newer peers and are not delivered.
+var knownTypes = map[ChatMessageType]bool{
+	Heartbeat:      true,
+	SetDescription: true,
+	SendVote:       true,
+	RevealVote:     true,
+	ClearVotes:     true,
+	ShowVotes:      true,
+	RequestState:   true,
+	SendState:      true,
+	SetDeck:        true,
+	SetBacklog:     true,
+	MoveStory:      true,
+	SetFacilitator: true,
+	RequestControl: true,
+	Revote:         true,
+}
+
+// decode decodes a message of any supported version and codec.
+func decode(data []byte) (*ChatMessage, error) {
+	if len(data) > 0 && data[0] == protobufPrefix {
+		return decodeProtobuf(data[1:])
+	}
+
+	cm := new(ChatMessage)
+	if err := json.Unmarshal(data, cm); err != nil {
+		return nil, err
+	}
+	if cm.Version == 0 {
+		return nil, ErrUnsupportedVersion
+	}
+	return cm, nil
+}
+
+// newMessageID returns a random ID for a message.
+func newMessageID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// the ID is informative, a message without it is still valid
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Versions returns the oldest and newest protocol versions of the peers in
+// the room, or zeros if we didn't hear from anyone yet.
+func (cr *ChatRoom) Versions() (oldest, newest int) {
+	cr.versionsMu.Lock()
+	defer cr.versionsMu.Unlock()
+
+	for _, id := range cr.ListPeers() {
+		v, ok := cr.versions[id]
+		if !ok {
+			continue
+		}
+		if oldest == 0 || v < oldest {
+			oldest = v
+		}
+		if v > newest {
+			newest = v
+		}
+	}
+	return oldest, newest
+}
+
+func (cr *ChatRoom) seenVersion(id peer.ID, version int) {
+	cr.versionsMu.Lock()
+	defer cr.versionsMu.Unlock()
+
+	if cr.versions == nil {
+		cr.versions = map[peer.ID]int{}
+	}
+	cr.versions[id] = version
+}
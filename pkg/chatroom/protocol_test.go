@@ -0,0 +1,128 @@
+package chatroom
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// vote is the message of the fixtures, as peers of the current version
+// send it.
+var vote = &ChatMessage{
+	Version:     Version,
+	ID:          "5f2b1c9a0d3e4f67",
+	MessageType: SendVote,
+	SenderID:    "12D3KooWKH8Fobsu46JqGuApoPmZaKzxV91dknaDYmy3c71oyxyV",
+	SenderNick:  "alice",
+	SentAt:      time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC),
+	Round:       3,
+	Payload:     []byte(`{"commitment":"9b74c9897bac770ffc029102a200c5de"}`),
+}
+
+func withVersion(m *ChatMessage, version int) *ChatMessage {
+	c := *m
+	c.Version = version
+	return &c
+}
+
+func TestEncodeGolden(t *testing.T) {
+	tests := []struct {
+		file  string
+		codec Codec
+	}{
+		{"v3-send-vote.json", CodecJSON},
+		{"v3-send-vote.pb", CodecProtobuf},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := encode(vote, tt.codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", tt.file)
+			if *update {
+				if err := os.WriteFile(path, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("encode() = %q, want the contents of %s %q", got, path, want)
+			}
+		})
+	}
+}
+
+func TestDecodeGolden(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    *ChatMessage
+		wantErr error
+	}{
+		// the flat messages of the first releases sent the votes in plain
+		// text, they can't be understood
+		{file: "v1-send-vote.json", wantErr: ErrUnsupportedVersion},
+		{file: "v2-send-vote.json", want: withVersion(vote, 2)},
+		{file: "v3-send-vote.json", want: vote},
+		{file: "v3-send-vote.pb", want: vote},
+		// newer versions only add fields, which are ignored
+		{file: "v4-send-vote.json", want: withVersion(vote, 4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decode(bytes.TrimSpace(data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+
+			// payloads are compared as values, since newer versions can
+			// add fields to them too
+			var p, want VotePayload
+			if err := got.Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.want.Decode(&want); err != nil {
+				t.Fatal(err)
+			}
+			if p != want {
+				t.Errorf("payload = %+v, want %+v", p, want)
+			}
+			g, w := *got, *tt.want
+			g.Payload, w.Payload = nil, nil
+			if !reflect.DeepEqual(g, w) {
+				t.Errorf("decode() = %+v, want %+v", g, w)
+			}
+		})
+	}
+}
+
+func TestKnownTypes(t *testing.T) {
+	data := []byte(`{"version":4,"type":"send-emoji","sender_id":"12D3KooWKH8Fobsu46JqGuApoPmZaKzxV91dknaDYmy3c71oyxyV","payload":{"emoji":"🎉"}}`)
+	cm, err := decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if knownTypes[cm.MessageType] {
+		t.Errorf("%s is delivered, but only newer peers know it", cm.MessageType)
+	}
+	if !knownTypes[SendVote] {
+		t.Errorf("%s isn't delivered", SendVote)
+	}
+}
@@ -0,0 +1,209 @@
+package chatroom
+
+import (
+	"errors"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+)
+
+// OutboxSize is the number of messages kept while reconnecting.
+const OutboxSize = 64
+
+// OutboxTTL is how long a message is kept while reconnecting. Older ones
+// are about a room that moved on, they are dropped.
+const OutboxTTL = 5 * time.Minute
+
+// ErrOutboxFull is returned by Publish when too many messages were queued
+// while reconnecting. It is transient, the message can be published again
+// once the room is back.
+var ErrOutboxFull = errors.New("too many messages waiting for the room to reconnect")
+
+// ConnectionCheckInterval is how often we check if we lost the
+// connection.
+const ConnectionCheckInterval = time.Second
+
+// Backoff between attempts to subscribe again to the room.
+const (
+	MinBackoff = time.Second
+	MaxBackoff = time.Minute
+)
+
+// Reconnecting tells if we lost the connection to the room, either because
+// the subscription or a publish failed, or because the host lost all its
+// connections. The room being empty isn't enough, the others may have just
+// left. Messages published meanwhile are queued until we reconnect.
+func (cr *ChatRoom) Reconnecting() bool {
+	cr.connMu.Lock()
+	defer cr.connMu.Unlock()
+
+	return cr.reconnecting
+}
+
+// Reconnections returns how many times we reconnected to the room, so that
+// the session can restore its state after each one.
+func (cr *ChatRoom) Reconnections() int {
+	cr.connMu.Lock()
+	defer cr.connMu.Unlock()
+
+	return cr.reconnections
+}
+
+// connectionLoop follows the connections of the host and the
+// subscription to tell when we lose the room and when it is back.
+func (cr *ChatRoom) connectionLoop() {
+	ticker := time.NewTicker(ConnectionCheckInterval)
+	defer ticker.Stop()
+
+	wasOnline := false
+	for {
+		select {
+		case <-cr.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !cr.online() {
+			if wasOnline {
+				cr.setReconnecting(true)
+			}
+			continue
+		}
+		wasOnline = true
+		cr.subMu.Lock()
+		subscribed := cr.sub != nil
+		cr.subMu.Unlock()
+		if subscribed {
+			cr.setReconnecting(false)
+			// in case a flush stopped early
+			cr.flushOutbox()
+		}
+	}
+}
+
+// online tells if the host is connected to any peer, the ones of the room
+// or others like the bootstrap servers.
+func (cr *ChatRoom) online() bool {
+	return cr.network == nil || len(cr.network.Conns()) > 0
+}
+
+// setReconnecting changes the connection state. Once reconnected, the
+// messages queued meanwhile are sent.
+func (cr *ChatRoom) setReconnecting(reconnecting bool) {
+	cr.connMu.Lock()
+	was := cr.reconnecting
+	cr.reconnecting = reconnecting
+	if was && !reconnecting {
+		cr.reconnections++
+	}
+	cr.connMu.Unlock()
+
+	switch {
+	case !was && reconnecting:
+		cr.record(activity.Error, "lost the connection to the room, reconnecting")
+	case was && !reconnecting:
+		cr.record(activity.Network, "reconnected to the room")
+		cr.flushOutbox()
+	}
+}
+
+// resubscribe subscribes to the room again, with backoff, after the
+// subscription failed. It returns false when the room is left.
+func (cr *ChatRoom) resubscribe() bool {
+	cr.subMu.Lock()
+	if cr.sub != nil {
+		cr.sub.Cancel()
+		cr.sub = nil
+	}
+	cr.subMu.Unlock()
+	cr.setReconnecting(true)
+
+	delay := MinBackoff
+	for {
+		select {
+		case <-cr.ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+
+		sub, err := cr.topic.Subscribe()
+		if err == nil {
+			cr.subMu.Lock()
+			cr.sub = sub
+			cr.subMu.Unlock()
+			return true
+		}
+
+		delay *= 2
+		if delay > MaxBackoff {
+			delay = MaxBackoff
+		}
+		cr.record(activity.Error, "can't subscribe to the room, trying again in %s: %s", delay, err)
+	}
+}
+
+// queue keeps a message to send once we reconnect, when we are
+// reconnecting or older messages weren't all sent yet, so that it doesn't
+// overtake them. It tells if the message was queued. Heartbeats are only
+// useful right away, so they are dropped instead.
+func (cr *ChatRoom) queue(m *ChatMessage) (bool, error) {
+	cr.connMu.Lock()
+	defer cr.connMu.Unlock()
+
+	if !cr.reconnecting && !cr.flushing && len(cr.outbox) == 0 {
+		return false, nil
+	}
+	if m.MessageType == Heartbeat {
+		return true, nil
+	}
+	if len(cr.outbox) >= OutboxSize {
+		return true, ErrOutboxFull
+	}
+	cr.outbox = append(cr.outbox, m)
+	return true, nil
+}
+
+// flushOutbox sends the messages queued while reconnecting, in order,
+// including the ones queued while flushing. The stale ones and the ones
+// that can't be encoded are dropped. When one fails to publish, it stays queued with the rest
+// and we are reconnecting again.
+func (cr *ChatRoom) flushOutbox() {
+	cr.connMu.Lock()
+	if cr.flushing {
+		cr.connMu.Unlock()
+		return
+	}
+	cr.flushing = true
+	cr.connMu.Unlock()
+
+	for {
+		cr.connMu.Lock()
+		// stop flushing along with the check, or a message could be queued
+		// after it with nobody left to send it
+		if cr.reconnecting || len(cr.outbox) == 0 {
+			cr.flushing = false
+			cr.connMu.Unlock()
+			return
+		}
+		m := cr.outbox[0]
+		cr.outbox = cr.outbox[1:]
+		stale := m.Round < cr.round || time.Since(m.SentAt) > OutboxTTL
+		cr.connMu.Unlock()
+
+		if stale {
+			continue
+		}
+		msgBytes, err := cr.marshal(m)
+		if err != nil {
+			continue
+		}
+		if err := cr.publish(m, msgBytes); err != nil {
+			cr.connMu.Lock()
+			cr.outbox = append([]*ChatMessage{m}, cr.outbox...)
+			cr.flushing = false
+			cr.connMu.Unlock()
+			cr.setReconnecting(true)
+			return
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package chatroom
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestHost(t *testing.T, ctx context.Context, opts ...libp2p.Option) (host.Host, *pubsub.PubSub) {
+	t.Helper()
+
+	h, err := libp2p.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	ps, err := pubsub.NewGossipSub(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, ps
+}
+
+// newTestRoom joins a room on a host without peers, which never
+// reconnects by itself.
+func newTestRoom(t *testing.T) *ChatRoom {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	h, ps := newTestHost(t, ctx, libp2p.NoListenAddrs)
+	cr, err := JoinChatRoom(ctx, ps, h.ID(), "alice", "team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cr
+}
+
+// waitFor waits until the condition is true, failing after a few checks of
+// the connection.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * ConnectionCheckInterval)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// published returns the descriptions sent, in order, until none is sent
+// for a while.
+func published(messages <-chan *ChatMessage) []string {
+	var got []string
+	for {
+		select {
+		case m := <-messages:
+			var d string
+			m.Decode(&d)
+			got = append(got, d)
+		case <-time.After(100 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+func TestFlushOutbox(t *testing.T) {
+	cr := newTestRoom(t)
+	messages, stop := cr.Watch()
+	defer stop()
+
+	cr.setReconnecting(true)
+	for _, d := range []string{"1", "2"} {
+		if err := cr.Publish(SetDescription, 0, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// reconnected, but the queued messages weren't sent yet
+	cr.connMu.Lock()
+	cr.reconnecting = false
+	cr.connMu.Unlock()
+	if err := cr.Publish(SetDescription, 0, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := published(messages); len(got) != 0 {
+		t.Fatalf("published %q before the queued messages", got)
+	}
+
+	cr.flushOutbox()
+	want := []string{"1", "2", "3"}
+	if got := published(messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+	if err := cr.Publish(SetDescription, 0, "4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := published(messages); !reflect.DeepEqual(got, []string{"4"}) {
+		t.Errorf("published %q once the outbox is empty, want %q", got, "4")
+	}
+}
+
+func TestFlushOutboxFails(t *testing.T) {
+	cr := newTestRoom(t)
+	messages, stop := cr.Watch()
+	defer stop()
+
+	cr.setReconnecting(true)
+	if err := cr.Publish(SetDescription, 0, "1"); err != nil {
+		t.Fatal(err)
+	}
+	// the room rejects messages of another sender
+	self := cr.Self
+	cr.Self = peer.ID("someone else")
+	if err := cr.Publish(SetDescription, 0, "2"); err != nil {
+		t.Fatal(err)
+	}
+	cr.Self = self
+	if err := cr.Publish(SetDescription, 0, "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	cr.setReconnecting(false)
+	if got, want := published(messages), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+	if !cr.Reconnecting() {
+		t.Error("not reconnecting after failing to send the queued messages")
+	}
+	cr.connMu.Lock()
+	defer cr.connMu.Unlock()
+	if len(cr.outbox) != 2 {
+		t.Errorf("%d messages queued, want 2", len(cr.outbox))
+	}
+}
+
+func TestFlushOutboxDropsStale(t *testing.T) {
+	cr := newTestRoom(t)
+	messages, stop := cr.Watch()
+	defer stop()
+
+	cr.setReconnecting(true)
+	for round, d := range []string{"1", "2", "3"} {
+		if err := cr.Publish(SetDescription, round, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cr.connMu.Lock()
+	// the room moved to the second round meanwhile, and the last message
+	// waited too long
+	cr.round = 1
+	cr.outbox[2].SentAt = time.Now().Add(-OutboxTTL - time.Second)
+	cr.connMu.Unlock()
+
+	cr.setReconnecting(false)
+	if got, want := published(messages), []string{"2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+}
+
+func TestReconnectingWhenOffline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	local := libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")
+	h1, ps1 := newTestHost(t, ctx, local)
+	h2, ps2 := newTestHost(t, ctx, local)
+	cr, err := JoinChatRoom(ctx, ps1, h1.ID(), "alice", "team-a", WithNetwork(h1.Network()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h2.Connect(ctx, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	topic, err := ps2.Join(topicName("team-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "bob to join the room", func() bool { return len(cr.ListPeers()) == 1 })
+
+	// the others leaving the room isn't losing the connection
+	sub.Cancel()
+	waitFor(t, "bob to leave the room", func() bool { return len(cr.ListPeers()) == 0 })
+	time.Sleep(2 * ConnectionCheckInterval)
+	if cr.Reconnecting() {
+		t.Error("reconnecting after the others left the room")
+	}
+
+	if err := h1.Network().ClosePeer(h2.ID()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the connection to be lost", cr.Reconnecting)
+}
+
+func TestFlushOutboxLeftover(t *testing.T) {
+	cr := newTestRoom(t)
+	messages, stop := cr.Watch()
+	defer stop()
+
+	// a message queued right as the last flush stopped
+	cr.connMu.Lock()
+	cr.flushing = true
+	cr.connMu.Unlock()
+	if err := cr.Publish(SetDescription, 0, "1"); err != nil {
+		t.Fatal(err)
+	}
+	cr.connMu.Lock()
+	cr.flushing = false
+	cr.connMu.Unlock()
+
+	// the connection check sends it
+	time.Sleep(2 * ConnectionCheckInterval)
+	if got, want := published(messages), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+}
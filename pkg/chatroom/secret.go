@@ -0,0 +1,75 @@
+package chatroom
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrDecrypt is returned for messages that can't be decrypted with the room key.
+var ErrDecrypt = errors.New("unable to decrypt message")
+
+// roomKey holds the keys derived from the room name and its secret.
+type roomKey struct {
+	aead  cipher.AEAD
+	topic string
+}
+
+// deriveRoomKey stretches the secret with argon2, using the room name as
+// salt, and derives from it the key to encrypt messages and the topic name,
+// so the room name is never advertised.
+func deriveRoomKey(roomName string, secret string) (*roomKey, error) {
+	master := argon2.IDKey([]byte(secret), []byte("p2p-estimator:"+roomName), 1, 64*1024, 4, 32)
+
+	encKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("message encryption")), encKey); err != nil {
+		return nil, err
+	}
+	topicID := make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte("topic name")), topicID); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &roomKey{
+		aead:  aead,
+		topic: "chat-room:" + hex.EncodeToString(topicID),
+	}, nil
+}
+
+// seal encrypts the data, prepending the random nonce used.
+func (k *roomKey) seal(data []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(data)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// open decrypts data encrypted with seal.
+func (k *roomKey) open(data []byte) ([]byte, error) {
+	if len(data) < k.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := data[:k.aead.NonceSize()], data[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1 @@
+5f2b1c9a0d3e4f67	send-vote"412D3KooWKH8Fobsu46JqGuApoPmZaKzxV91dknaDYmy3c71oyxyV*alice0ਖ8B1{"commitment":"9b74c9897bac770ffc029102a200c5de"}
\ No newline at end of file
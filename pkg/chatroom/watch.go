@@ -0,0 +1,43 @@
+package chatroom
+
+// WatchBufSize is the number of messages buffered for each watcher.
+// Watchers that fall behind lose messages.
+const WatchBufSize = 64
+
+// Watch returns a channel with a copy of every message sent and received
+// in the room, for observers that must not consume the Messages channel.
+// The channel is closed by calling stop.
+func (cr *ChatRoom) Watch() (messages <-chan *ChatMessage, stop func()) {
+	ch := make(chan *ChatMessage, WatchBufSize)
+
+	cr.watchMu.Lock()
+	defer cr.watchMu.Unlock()
+	if cr.watchers == nil {
+		cr.watchers = map[chan *ChatMessage]struct{}{}
+	}
+	cr.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		cr.watchMu.Lock()
+		defer cr.watchMu.Unlock()
+		if _, ok := cr.watchers[ch]; ok {
+			delete(cr.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// notifyWatchers sends a copy of the message to the watchers that have
+// room for it.
+func (cr *ChatRoom) notifyWatchers(cm *ChatMessage) {
+	cr.watchMu.Lock()
+	defer cr.watchMu.Unlock()
+
+	for ch := range cr.watchers {
+		m := *cm
+		select {
+		case ch <- &m:
+		default:
+		}
+	}
+}
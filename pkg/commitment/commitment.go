@@ -0,0 +1,66 @@
+package commitment
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// nonceSize is the number of random bytes mixed into every commitment so
+// that votes taken from a small deck can't be brute forced from the hash.
+const nonceSize = 16
+
+// ErrInvalidOpening is returned when an opening can't be decoded.
+var ErrInvalidOpening = errors.New("invalid commitment opening")
+
+// Opening holds the values needed to check a commitment once the round is
+// revealed.
+type Opening struct {
+	Vote  string
+	Nonce string
+}
+
+// Commit creates a commitment for the vote. The commitment can be published
+// right away and the opening must be kept secret until the reveal.
+func Commit(vote string) (string, Opening, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", Opening{}, err
+	}
+
+	o := Opening{
+		Vote:  vote,
+		Nonce: hex.EncodeToString(nonce),
+	}
+	return o.commitment(), o, nil
+}
+
+// Verify checks if the opening matches the commitment.
+func Verify(commitment string, o Opening) bool {
+	return subtle.ConstantTimeCompare([]byte(commitment), []byte(o.commitment())) == 1
+}
+
+// Encode serializes the opening so it can be sent in a chat message.
+func (o Opening) Encode() string {
+	return o.Nonce + ":" + o.Vote
+}
+
+// Decode parses an opening serialized with Opening.Encode.
+func Decode(s string) (Opening, error) {
+	nonce, vote, found := strings.Cut(s, ":")
+	if !found || len(nonce) != hex.EncodedLen(nonceSize) {
+		return Opening{}, ErrInvalidOpening
+	}
+	if _, err := hex.DecodeString(nonce); err != nil {
+		return Opening{}, ErrInvalidOpening
+	}
+	return Opening{Vote: vote, Nonce: nonce}, nil
+}
+
+func (o Opening) commitment() string {
+	sum := sha256.Sum256([]byte(o.Encode()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,104 @@
+package commitment
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	c, o, err := Commit("5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := Commit("5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		commitment string
+		opening    Opening
+		want       bool
+	}{
+		{"round trip", c, o, true},
+		{"tampered vote", c, Opening{Vote: "8", Nonce: o.Nonce}, false},
+		{"tampered nonce", c, Opening{Vote: o.Vote, Nonce: other.Nonce}, false},
+		{"another commitment", c, other, false},
+		{"malformed commitment", "not hex", o, false},
+		{"truncated commitment", c[:len(c)-2], o, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.commitment, tt.opening); got != tt.want {
+				t.Errorf("Verify() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitUsesANewNonce(t *testing.T) {
+	c1, _, err := Commit("5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, _, err := Commit("5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 == c2 {
+		t.Error("the same vote gave the same commitment twice")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	nonce := strings.Repeat("ab", nonceSize)
+	tests := []struct {
+		name    string
+		encoded string
+		want    Opening
+		wantErr bool
+	}{
+		{name: "vote", encoded: nonce + ":5", want: Opening{Vote: "5", Nonce: nonce}},
+		{name: "vote with a colon", encoded: nonce + ":1:2", want: Opening{Vote: "1:2", Nonce: nonce}},
+		{name: "empty vote", encoded: nonce + ":", want: Opening{Nonce: nonce}},
+		{name: "no separator", encoded: nonce, wantErr: true},
+		{name: "short nonce", encoded: nonce[2:] + ":5", wantErr: true},
+		{name: "malformed hex", encoded: strings.Repeat("zz", nonceSize) + ":5", wantErr: true},
+		{name: "empty", encoded: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.encoded)
+			if tt.wantErr {
+				if err != ErrInvalidOpening {
+					t.Errorf("Decode() error = %v, want ErrInvalidOpening", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	c, o, err := Commit("?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(o.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != o {
+		t.Errorf("Decode(Encode()) = %+v, want %+v", got, o)
+	}
+	if !Verify(c, got) {
+		t.Error("the decoded opening doesn't verify")
+	}
+}
@@ -0,0 +1,107 @@
+// Package control runs the commands of the frontends other than the text
+// UI, like the bot and the web UI, on an estimation session.
+package control
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/renato0307/p2p-estimator/pkg/backlog"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+)
+
+// Commands understood by Exec. Some take an argument, e.g. the card of a
+// vote.
+const (
+	Vote        = "vote"
+	Reveal      = "reveal"
+	Clear       = "clear"
+	Revote      = "revote"
+	Description = "description"
+	Deck        = "deck"
+	Backlog     = "backlog"
+	Next        = "next"
+	Previous    = "previous"
+	Skip        = "skip"
+	HandOver    = "handover"
+)
+
+// ErrUnknownCommand is returned by Exec for commands it doesn't know.
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Controller runs commands on a session.
+type Controller struct {
+	session *session.EstimationSession
+	decks   []deck.Deck
+}
+
+// New creates a controller for the session. The decks are the ones the
+// room can be switched to.
+func New(s *session.EstimationSession, decks []deck.Deck) *Controller {
+	return &Controller{session: s, decks: decks}
+}
+
+// Exec runs a command with its argument, if it takes one.
+func (c *Controller) Exec(command, arg string) error {
+	arg = strings.TrimSpace(arg)
+
+	switch command {
+	case Vote:
+		return c.vote(arg)
+	case Reveal:
+		return c.session.Reveal()
+	case Clear:
+		return c.session.Clear()
+	case Revote:
+		return c.session.Revote()
+	case Description:
+		return c.session.SetDescription(arg)
+	case Deck:
+		return c.changeDeck(arg)
+	case Backlog:
+		return c.loadBacklog(arg)
+	case Next:
+		return c.session.NextStory()
+	case Previous:
+		return c.session.PreviousStory()
+	case Skip:
+		return c.session.SkipStory()
+	case HandOver:
+		return c.handOver(arg)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownCommand, command)
+}
+
+func (c *Controller) vote(card string) error {
+	d := c.session.State().Deck
+	if _, ok := d.Position(card); !ok && card != deck.NoClue {
+		return fmt.Errorf("%q is not a card of the %s deck: %s", card, d.Name, strings.Join(d.Cards, " "))
+	}
+	return c.session.Vote(card)
+}
+
+func (c *Controller) changeDeck(name string) error {
+	d, ok := deck.Find(c.decks, name)
+	if !ok {
+		return fmt.Errorf("unknown deck %q", name)
+	}
+	return c.session.SetDeck(d)
+}
+
+func (c *Controller) loadBacklog(path string) error {
+	stories, err := backlog.Load(path)
+	if err != nil {
+		return err
+	}
+	return c.session.LoadBacklog(stories)
+}
+
+func (c *Controller) handOver(nick string) error {
+	p, ok := c.session.ParticipantByNick(nick)
+	if !ok {
+		return fmt.Errorf("there is nobody called %s in the room", nick)
+	}
+	return c.session.HandOver(p.ID)
+}
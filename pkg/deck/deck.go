@@ -0,0 +1,190 @@
+package deck
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NoClue is the card for participants that can't estimate the story.
+// It is part of every deck and it is ignored by the statistics.
+const NoClue = "?"
+
+// Deck is a set of cards participants choose from to estimate.
+type Deck struct {
+	Name string `json:"name"`
+	// Unit is shown next to numeric cards, e.g. "points".
+	Unit  string   `json:"unit,omitempty"`
+	Cards []string `json:"cards"`
+}
+
+var (
+	Fibonacci = Deck{
+		Name:  "fibonacci",
+		Unit:  "points",
+		Cards: []string{"0", "1", "2", "3", "5", "8", "13", "20", "40", NoClue},
+	}
+	ModifiedFibonacci = Deck{
+		Name:  "modified-fibonacci",
+		Unit:  "points",
+		Cards: []string{"0", "½", "1", "2", "3", "5", "8", "13", "20", "40", "100", NoClue},
+	}
+	PowersOfTwo = Deck{
+		Name:  "powers-of-two",
+		Unit:  "points",
+		Cards: []string{"0", "1", "2", "4", "8", "16", "32", "64", NoClue},
+	}
+	TShirt = Deck{
+		Name:  "t-shirt",
+		Cards: []string{"XS", "S", "M", "L", "XL", "XXL", NoClue},
+	}
+	Hours = Deck{
+		Name:  "hours",
+		Unit:  "hours",
+		Cards: []string{"1", "2", "4", "8", "16", "24", "40", NoClue},
+	}
+)
+
+// Default is the deck used when none is chosen.
+var Default = Fibonacci
+
+// Defaults returns the predefined decks.
+func Defaults() []Deck {
+	return []Deck{Fibonacci, ModifiedFibonacci, PowersOfTwo, TShirt, Hours}
+}
+
+// Find returns the deck with the name.
+func Find(decks []Deck, name string) (Deck, bool) {
+	for _, d := range decks {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Deck{}, false
+}
+
+// Load reads custom decks from a JSON file with a list of decks, e.g.
+//
+//	[{"name": "days", "unit": "days", "cards": ["1", "2", "3", "5"]}]
+//
+// The NoClue card is added to the decks that don't have it.
+func Load(path string) ([]Deck, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var decks []Deck
+	if err := json.Unmarshal(data, &decks); err != nil {
+		return nil, fmt.Errorf("invalid deck file %s: %w", path, err)
+	}
+
+	for i, d := range decks {
+		if d.Name == "" || len(d.Cards) == 0 {
+			return nil, fmt.Errorf("invalid deck file %s: decks need a name and cards", path)
+		}
+		if d.index(NoClue) < 0 {
+			decks[i].Cards = append(d.Cards, NoClue)
+		}
+	}
+	return decks, nil
+}
+
+// Numeric tells if all the cards, except NoClue, are numbers.
+func (d Deck) Numeric() bool {
+	for _, c := range d.Cards {
+		if c == NoClue {
+			continue
+		}
+		if _, ok := number(c); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Value maps a card to a number used in statistics. Numeric decks use the
+// card value, the others the position of the card in the deck, so that
+// e.g. the average of a t-shirt deck can be computed. It returns false
+// for NoClue and cards not in the deck.
+func (d Deck) Value(card string) (float64, bool) {
+	i := d.index(card)
+	if i < 0 || card == NoClue {
+		return 0, false
+	}
+	if d.Numeric() {
+		return number(card)
+	}
+	return float64(i), true
+}
+
+// Position returns the position of a card in the deck, used to tell how
+// many cards apart votes are. It returns false for NoClue and cards not in
+// the deck.
+func (d Deck) Position(card string) (int, bool) {
+	i := d.index(card)
+	if i < 0 || card == NoClue {
+		return 0, false
+	}
+	return i, true
+}
+
+// Nearest returns the card with the value closest to v.
+func (d Deck) Nearest(v float64) string {
+	nearest := ""
+	distance := math.Inf(1)
+	for _, c := range d.Cards {
+		cv, ok := d.Value(c)
+		if !ok {
+			continue
+		}
+		if dist := math.Abs(cv - v); dist < distance {
+			nearest, distance = c, dist
+		}
+	}
+	return nearest
+}
+
+// Format shows a value computed from card values, like an average. Values
+// of non-numeric decks are shown as the nearest card.
+func (d Deck) Format(v float64) string {
+	if !d.Numeric() {
+		return "~" + d.Nearest(v)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// Title returns how a card is shown to the user, e.g. "5 points".
+func (d Deck) Title(card string) string {
+	if card == NoClue {
+		return "No clue 🤷"
+	}
+	if d.Unit == "" {
+		return card
+	}
+	if v, ok := number(card); ok && v == 1 {
+		return card + " " + strings.TrimSuffix(d.Unit, "s")
+	}
+	return card + " " + d.Unit
+}
+
+func (d Deck) index(card string) int {
+	for i, c := range d.Cards {
+		if c == card {
+			return i
+		}
+	}
+	return -1
+}
+
+// number parses numeric cards, which can also be fractions like "½".
+func number(card string) (float64, bool) {
+	if card == "½" {
+		return 0.5, true
+	}
+	v, err := strconv.ParseFloat(card, 64)
+	return v, err == nil
+}
@@ -2,24 +2,36 @@ package discovery
 
 import (
 	"context"
-	"log"
 	"sync"
 
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 )
 
-func NewDHT(ctx context.Context, host host.Host, bootstrapPeers []multiaddr.Multiaddr) (*dht.IpfsDHT, error) {
+// NewDHT creates a Kademlia DHT and connects it to the bootstrap peers.
+// Bootstrap servers run the DHT in server mode so other peers can use them
+// for peer discovery via the dht. The connections to the bootstrap peers
+// are recorded in the activity log.
+func NewDHT(ctx context.Context, host host.Host, bootstrapPeers []multiaddr.Multiaddr, server bool, events *activity.Log) (*dht.IpfsDHT, error) {
 	var options []dht.Option
 
-	// if no bootstrap peers give this peer act as a bootstraping node
-	// other peers can use this peers ipfs address for peer discovery via dht
-	if len(bootstrapPeers) == 0 {
+	if server {
 		options = append(options, dht.Mode(dht.ModeServer))
 	}
 
+	peerInfos := make([]peer.AddrInfo, 0, len(bootstrapPeers))
+	for _, peerAddr := range bootstrapPeers {
+		peerInfo, err := peer.AddrInfoFromP2pAddr(peerAddr)
+		if err != nil {
+			return nil, err
+		}
+		peerInfos = append(peerInfos, *peerInfo)
+	}
+
 	kdht, err := dht.New(ctx, host, options...)
 	if err != nil {
 		return nil, err
@@ -30,18 +42,16 @@ func NewDHT(ctx context.Context, host host.Host, bootstrapPeers []multiaddr.Mult
 	}
 
 	var wg sync.WaitGroup
-	for _, peerAddr := range bootstrapPeers {
-		peerinfo, _ := peer.AddrInfoFromP2pAddr(peerAddr)
-
+	for _, peerInfo := range peerInfos {
 		wg.Add(1)
-		go func() {
+		go func(peerInfo peer.AddrInfo) {
 			defer wg.Done()
-			if err := host.Connect(ctx, *peerinfo); err != nil {
-				log.Printf("error while connecting to node %q: %-v", peerinfo, err)
+			if err := host.Connect(ctx, peerInfo); err != nil {
+				events.Add(activity.Error, "error while connecting to node %q: %-v", peerInfo, err)
 			} else {
-				log.Printf("connection established with bootstrap node: %q", *peerinfo)
+				events.Add(activity.Network, "connection established with bootstrap node: %q", peerInfo)
 			}
-		}()
+		}(peerInfo)
 	}
 	wg.Wait()
 
@@ -2,17 +2,23 @@ package discovery
 
 import (
 	"context"
-	"log"
 	"time"
 
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	dutil "github.com/libp2p/go-libp2p/p2p/discovery/util"
 )
 
-func Discover(ctx context.Context, h host.Host, dht *dht.IpfsDHT, rendezvous string) {
+// Discover advertises this peer under the rendezvous string and keeps
+// connecting to the other peers found with it in the DHT. The DHT can keep
+// peers that are long gone, so the ones that can't be reached are dialed
+// with the same backoff as Redial.
+func Discover(ctx context.Context, h host.Host, dht *dht.IpfsDHT, rendezvous string, events *activity.Log) {
 	var routingDiscovery = routing.NewRoutingDiscovery(dht)
 
 	dutil.Advertise(ctx, routingDiscovery, rendezvous)
@@ -20,6 +26,8 @@ func Discover(ctx context.Context, h host.Host, dht *dht.IpfsDHT, rendezvous str
 	ticker := time.NewTicker(time.Second * 1)
 	defer ticker.Stop()
 
+	states := map[peer.ID]*redialState{}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -27,22 +35,38 @@ func Discover(ctx context.Context, h host.Host, dht *dht.IpfsDHT, rendezvous str
 		case <-ticker.C:
 			peers, err := dutil.FindPeers(ctx, routingDiscovery, rendezvous)
 			if err != nil {
-				log.Fatal(err)
+				// the DHT may not have enough peers yet, try again later
+				continue
 			}
 
 			for _, p := range peers {
-				if p.ID == h.ID() {
+				if p.ID == h.ID() || len(p.Addrs) == 0 {
 					continue
 				}
-				if h.Network().Connectedness(p.ID) != network.Connected {
-					// TODO - log: log.Printf("connecting to peer %s @ %s\n", p.ID.Pretty(), p.Addrs[0])
-					_, err = h.Network().DialPeer(ctx, p.ID)
-					if err != nil {
-						log.Printf("error connecting to peer %s: %s\n", p.ID.Pretty(), err)
-						continue
+				if h.Network().Connectedness(p.ID) == network.Connected {
+					delete(states, p.ID)
+					continue
+				}
+				st, ok := states[p.ID]
+				if !ok {
+					st = newRedialState()
+					states[p.ID] = st
+				}
+				if time.Now().Before(st.next) {
+					continue
+				}
+
+				dialCtx, cancel := context.WithTimeout(ctx, DialTimeout)
+				err = h.Connect(dialCtx, p)
+				cancel()
+				if err != nil {
+					if st.failed() {
+						events.Add(activity.Error, "can't reach peer %s found in the DHT, trying again: %s", p.ID.ShortString(), err)
 					}
-					// TODO - log: log.Printf("connected to peer %s\n", p.ID.Pretty())
+					continue
 				}
+				delete(states, p.ID)
+				events.Add(activity.Network, "connected to peer %s found in the DHT", p.ID.ShortString())
 			}
 		}
 	}
@@ -0,0 +1,118 @@
+package discovery
+
+import (
+	"context"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RedialWindow is for how long after we last heard from a peer we keep
+// trying to reach it. The peerstore keeps the addresses of recently
+// connected peers for about as long.
+const RedialWindow = 10 * time.Minute
+
+// RedialInterval is how often we look for known peers we are no longer
+// connected to.
+const RedialInterval = 2 * time.Second
+
+// Backoff between attempts to dial the same peer.
+const (
+	MinRedialBackoff = time.Second
+	MaxRedialBackoff = time.Minute
+)
+
+// DialTimeout limits each attempt to dial a peer.
+const DialTimeout = 10 * time.Second
+
+type redialState struct {
+	next    time.Time
+	backoff time.Duration
+	dialing bool
+}
+
+func newRedialState() *redialState {
+	return &redialState{backoff: MinRedialBackoff}
+}
+
+// failed waits longer before dialing again. It tells if it was the first
+// failure, the only one logged since the next ones would flood the log.
+func (st *redialState) failed() bool {
+	first := st.backoff == MinRedialBackoff
+	st.next = time.Now().Add(st.backoff)
+	st.backoff *= 2
+	if st.backoff > MaxRedialBackoff {
+		st.backoff = MaxRedialBackoff
+	}
+	return first
+}
+
+// Redial keeps dialing the known peers we lost the connection to, using
+// the addresses kept in the peerstore, with an exponential backoff for
+// each peer. It stops when the context is done.
+func Redial(ctx context.Context, h host.Host, known func() []peer.ID, events *activity.Log) {
+	ticker := time.NewTicker(RedialInterval)
+	defer ticker.Stop()
+
+	type result struct {
+		id  peer.ID
+		err error
+	}
+	results := make(chan result)
+	states := map[peer.ID]*redialState{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-results:
+			st, ok := states[r.id]
+			if !ok {
+				continue
+			}
+			st.dialing = false
+			if r.err == nil {
+				delete(states, r.id)
+				events.Add(activity.Network, "reconnected to peer %s", r.id.ShortString())
+				continue
+			}
+			if st.failed() {
+				events.Add(activity.Error, "can't reach peer %s, trying again: %s", r.id.ShortString(), r.err)
+			}
+		case <-ticker.C:
+			for _, id := range known() {
+				if id == h.ID() || h.Network().Connectedness(id) == network.Connected {
+					delete(states, id)
+					continue
+				}
+				st, ok := states[id]
+				if !ok {
+					st = newRedialState()
+					states[id] = st
+				}
+				if st.dialing || time.Now().Before(st.next) {
+					continue
+				}
+				addrs := h.Peerstore().PeerInfo(id)
+				if len(addrs.Addrs) == 0 {
+					continue
+				}
+
+				st.dialing = true
+				go func(info peer.AddrInfo) {
+					dialCtx, cancel := context.WithTimeout(ctx, DialTimeout)
+					defer cancel()
+					err := h.Connect(dialCtx, info)
+					select {
+					case results <- result{id: info.ID, err: err}:
+					case <-ctx.Done():
+					}
+				}(addrs)
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedialBackoff(t *testing.T) {
+	st := newRedialState()
+	var logged int
+	for i := 0; i < 10; i++ {
+		if st.failed() {
+			logged++
+		}
+	}
+	if logged != 1 {
+		t.Errorf("%d failures logged, want only the first", logged)
+	}
+	if st.backoff != MaxRedialBackoff {
+		t.Errorf("backoff = %s, want %s", st.backoff, MaxRedialBackoff)
+	}
+	if wait := time.Until(st.next); wait <= MaxRedialBackoff/2 {
+		t.Errorf("next attempt in %s, want about %s", wait, MaxRedialBackoff)
+	}
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats the history can be exported to.
+const (
+	CSV      = "csv"
+	JSON     = "json"
+	Markdown = "markdown"
+)
+
+// Export writes the rounds in the format given.
+func Export(w io.Writer, rounds []Round, format string) error {
+	switch format {
+	case CSV:
+		return exportCSV(w, rounds)
+	case JSON:
+		return exportJSON(w, rounds)
+	case Markdown, "md":
+		return exportMarkdown(w, rounds)
+	}
+	return fmt.Errorf("unknown export format %q, use %s, %s or %s", format, CSV, JSON, Markdown)
+}
+
+// exportCSV writes a row per vote, so the file can be easily filtered.
+func exportCSV(w io.Writer, rounds []Round) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"room", "story", "round", "revealed_at", "estimate", "nick", "peer_id", "vote"})
+	if err != nil {
+		return err
+	}
+	for _, r := range rounds {
+		for _, v := range r.Votes {
+			err := cw.Write([]string{
+				r.Room,
+				r.Story,
+				strconv.Itoa(r.Round),
+				r.RevealedAt.Format(time.RFC3339),
+				r.Estimate,
+				v.Nick,
+				v.PeerID,
+				v.Vote,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportJSON(w io.Writer, rounds []Round) error {
+	if rounds == nil {
+		rounds = []Round{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rounds)
+}
+
+// exportMarkdown writes a table per room, ready to paste in sprint notes.
+func exportMarkdown(w io.Writer, rounds []Round) error {
+	room := ""
+	for i, r := range rounds {
+		if i == 0 || r.Room != room {
+			room = r.Room
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "## %s\n\n", room)
+			fmt.Fprintln(w, "| Story | Round | Revealed at | Estimate | Votes |")
+			fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+		}
+
+		votes := make([]string, len(r.Votes))
+		for j, v := range r.Votes {
+			votes[j] = fmt.Sprintf("%s: %s", v.Nick, v.Vote)
+		}
+		_, err := fmt.Fprintf(w, "| %s | %d | %s | %s | %s |\n",
+			markdownEscape(r.Story),
+			r.Round,
+			r.RevealedAt.Format("2006-01-02 15:04"),
+			markdownEscape(r.Estimate),
+			markdownEscape(strings.Join(votes, ", ")))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
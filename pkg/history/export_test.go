@@ -0,0 +1,136 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRounds = []Round{
+	{
+		Room:       "team-a",
+		Story:      "PROJ-1: login page",
+		Round:      1,
+		RevealedAt: time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC),
+		Estimate:   "5",
+		Votes: []Vote{
+			{PeerID: "12D3KooWA", Nick: "alice", Vote: "3"},
+			{PeerID: "12D3KooWB", Nick: "bob", Vote: "8"},
+		},
+	},
+	{
+		Room:       "team-a",
+		Story:      "PROJ-2: a | b, \"quoted\"",
+		Round:      2,
+		RevealedAt: time.Date(2022, 12, 1, 10, 5, 0, 0, time.UTC),
+		Estimate:   "?",
+		Votes: []Vote{
+			{PeerID: "12D3KooWA", Nick: "alice", Vote: "?"},
+		},
+	},
+	{
+		Room:       "team-b",
+		Story:      "sizing",
+		Round:      1,
+		RevealedAt: time.Date(2022, 12, 2, 9, 30, 0, 0, time.UTC),
+		Estimate:   "M",
+		Votes: []Vote{
+			{PeerID: "12D3KooWC", Nick: "carol", Vote: "M"},
+		},
+	},
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		format string
+		rounds []Round
+		want   string
+	}{
+		{
+			format: CSV,
+			rounds: testRounds,
+			want: `room,story,round,revealed_at,estimate,nick,peer_id,vote
+team-a,PROJ-1: login page,1,2022-12-01T10:00:00Z,5,alice,12D3KooWA,3
+team-a,PROJ-1: login page,1,2022-12-01T10:00:00Z,5,bob,12D3KooWB,8
+team-a,"PROJ-2: a | b, ""quoted""",2,2022-12-01T10:05:00Z,?,alice,12D3KooWA,?
+team-b,sizing,1,2022-12-02T09:30:00Z,M,carol,12D3KooWC,M
+`,
+		},
+		{
+			format: CSV,
+			want: `room,story,round,revealed_at,estimate,nick,peer_id,vote
+`,
+		},
+		{
+			format: Markdown,
+			rounds: testRounds,
+			want: `## team-a
+
+| Story | Round | Revealed at | Estimate | Votes |
+| --- | --- | --- | --- | --- |
+| PROJ-1: login page | 1 | 2022-12-01 10:00 | 5 | alice: 3, bob: 8 |
+| PROJ-2: a \| b, "quoted" | 2 | 2022-12-01 10:05 | ? | alice: ? |
+
+## team-b
+
+| Story | Round | Revealed at | Estimate | Votes |
+| --- | --- | --- | --- | --- |
+| sizing | 1 | 2022-12-02 09:30 | M | carol: M |
+`,
+		},
+		{
+			format: "md",
+			rounds: testRounds[2:],
+			want: `## team-b
+
+| Story | Round | Revealed at | Estimate | Votes |
+| --- | --- | --- | --- | --- |
+| sizing | 1 | 2022-12-02 09:30 | M | carol: M |
+`,
+		},
+		{
+			format: Markdown,
+			want:   "",
+		},
+		{
+			format: JSON,
+			want:   "[]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Export(&buf, tt.rounds, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Export() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(&buf, testRounds, JSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Round
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, testRounds) {
+		t.Errorf("Export() = %+v, want %+v", got, testRounds)
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	err := Export(&bytes.Buffer{}, testRounds, "xml")
+	if err == nil || !strings.Contains(err.Error(), `"xml"`) {
+		t.Errorf("Export() = %v, want an unknown format error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultFileName is the name of the history file inside the config dir.
+const DefaultFileName = "history.jsonl"
+
+// Round is a round of votes that was revealed.
+type Round struct {
+	Room       string    `json:"room"`
+	Story      string    `json:"story"`
+	Round      int       `json:"round"`
+	Votes      []Vote    `json:"votes"`
+	RevealedAt time.Time `json:"revealedAt"`
+	// Estimate is the card agreed by the room, the nearest to the average.
+	Estimate string `json:"estimate"`
+}
+
+// Vote is the vote of a participant in a round.
+type Vote struct {
+	PeerID string `json:"peerId"`
+	Nick   string `json:"nick"`
+	Vote   string `json:"vote"`
+}
+
+// Store keeps the rounds in a file, one JSON object per line.
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// DefaultPath returns where the history is kept by default, inside the
+// user's config dir.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "p2p-estimator", DefaultFileName), nil
+}
+
+// NewStore returns the store for the history file in path, which is
+// created when the first round is added.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Add appends a round to the history.
+func (s *Store) Add(r Round) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(r)
+}
+
+// Rounds returns the rounds in the history, oldest first. If room isn't
+// empty only the rounds of that room are returned.
+func (s *Store) Rounds(room string) ([]Round, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rounds []Round
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Round
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// skip lines damaged, e.g. by a crash while writing
+			continue
+		}
+		if room == "" || r.Room == room {
+			rounds = append(rounds, r)
+		}
+	}
+	return rounds, scanner.Err()
+}
@@ -0,0 +1,103 @@
+package identity
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// DefaultFileName is the name of the key file inside the config dir.
+const DefaultFileName = "identity.key"
+
+// MaxDefaultFiles is the number of default key files tried when other
+// peers on the same machine use the first ones.
+const MaxDefaultFiles = 32
+
+// ErrInUse is returned when another running peer uses the key file.
+var ErrInUse = errors.New("the identity is used by another running peer")
+
+// DefaultPath returns where the identity key is kept by default, inside the
+// user's config dir.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "p2p-estimator", DefaultFileName), nil
+}
+
+// OpenDefault opens the first default key file no other running peer
+// uses: identity.key, then identity-2.key and so on. Each peer running on
+// the machine gets its own ID, which stays the same across runs as long
+// as they are started in the same order.
+func OpenDefault() (crypto.PrivKey, string, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, "", err
+	}
+	ext := filepath.Ext(path)
+	base := path[:len(path)-len(ext)]
+
+	for i := 1; i <= MaxDefaultFiles; i++ {
+		p := path
+		if i > 1 {
+			p = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		key, err := Open(p)
+		if errors.Is(err, ErrInUse) {
+			continue
+		}
+		return key, p, err
+	}
+	return nil, "", ErrInUse
+}
+
+// Open locks the key file, so no other peer uses the same ID while this
+// process runs, and loads the key with LoadOrCreate.
+func Open(path string) (crypto.PrivKey, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := lock(path + ".lock"); err != nil {
+		return nil, err
+	}
+	return LoadOrCreate(path)
+}
+
+// LoadOrCreate reads the private key stored in path. If the file doesn't
+// exist, a new Ed25519 key is generated and stored there, so the peer keeps
+// the same ID across runs.
+func LoadOrCreate(path string) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return crypto.UnmarshalPrivateKey(data)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key must only be readable by its owner
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package identity
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenLocksTheKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alice.key")
+
+	key, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrInUse) {
+		t.Fatalf("second Open() = %v, want ErrInUse", err)
+	}
+
+	// the key is kept across runs
+	again, err := LoadOrCreate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equals(again) {
+		t.Error("LoadOrCreate() returned another key")
+	}
+}
+
+func TestOpenDefaultGivesEachPeerItsKey(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	first, firstPath, err := OpenDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, secondPath, err := OpenDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(firstPath) != DefaultFileName {
+		t.Errorf("first key in %s, want %s", firstPath, DefaultFileName)
+	}
+	if filepath.Base(secondPath) != "identity-2.key" {
+		t.Errorf("second key in %s, want identity-2.key", secondPath)
+	}
+	if first.Equals(second) {
+		t.Error("both peers got the same key")
+	}
+}
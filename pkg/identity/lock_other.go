@@ -0,0 +1,9 @@
+//go:build !unix
+
+package identity
+
+// lock does nothing where flock isn't available, peers sharing the key
+// file must use -identity.
+func lock(path string) error {
+	return nil
+}
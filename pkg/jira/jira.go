@@ -0,0 +1,172 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultStoryPointsField is the story points custom field in Jira Cloud.
+// Each Jira instance can have it under a different ID.
+const DefaultStoryPointsField = "customfield_10016"
+
+var (
+	// ErrNotConfigured is returned when the Jira base URL or token are missing.
+	ErrNotConfigured = errors.New("jira is not configured, set JIRA_BASE_URL and JIRA_API_TOKEN")
+	// ErrInvalidBaseURL is returned when the base URL isn't an absolute
+	// http or https URL.
+	ErrInvalidBaseURL = errors.New("the jira base URL must be like https://example.atlassian.net")
+)
+
+// Config holds the settings to connect to Jira.
+type Config struct {
+	// BaseURL of the Jira instance, e.g. https://example.atlassian.net
+	BaseURL string
+	// Email is used with the token for basic auth in Jira Cloud. Without it
+	// the token is sent as a bearer token, as personal access tokens of
+	// Jira Server and Data Center.
+	Email string
+	Token string
+	// StoryPointsField is the ID of the custom field with the story points.
+	StoryPointsField string
+}
+
+// ConfigFromEnv reads the config from the JIRA_BASE_URL, JIRA_EMAIL,
+// JIRA_API_TOKEN and JIRA_STORY_POINTS_FIELD environment variables.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		BaseURL:          os.Getenv("JIRA_BASE_URL"),
+		Email:            os.Getenv("JIRA_EMAIL"),
+		Token:            os.Getenv("JIRA_API_TOKEN"),
+		StoryPointsField: os.Getenv("JIRA_STORY_POINTS_FIELD"),
+	}
+	if cfg.StoryPointsField == "" {
+		cfg.StoryPointsField = DefaultStoryPointsField
+	}
+	return cfg
+}
+
+// Client talks with the Jira REST API.
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+}
+
+// Issue is the part of a Jira issue used for estimations.
+type Issue struct {
+	Key     string
+	Summary string
+}
+
+// NewClient creates a client for the Jira instance in the config.
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" || cfg.Token == "" {
+		return nil, ErrNotConfigured
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidBaseURL
+	}
+	if cfg.StoryPointsField == "" {
+		cfg.StoryPointsField = DefaultStoryPointsField
+	}
+
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// GetIssue loads the issue with the key, e.g. PROJ-123.
+func (c *Client) GetIssue(ctx context.Context, key string) (*Issue, error) {
+	var resp struct {
+		Key    string
+		Fields struct {
+			Summary string
+		}
+	}
+	err := c.do(ctx, http.MethodGet, issuePath(key)+"?fields=summary", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &Issue{Key: resp.Key, Summary: resp.Fields.Summary}, nil
+}
+
+// SetStoryPoints writes the estimate to the story points field of the issue.
+func (c *Client) SetStoryPoints(ctx context.Context, key string, points float64) error {
+	body := map[string]interface{}{
+		"fields": map[string]interface{}{
+			c.cfg.StoryPointsField: points,
+		},
+	}
+	return c.do(ctx, http.MethodPut, issuePath(key), body, nil)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.cfg.BaseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.cfg.Email != "" {
+		req.SetBasicAuth(c.cfg.Email, c.cfg.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return responseError(method, path, resp)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// responseError builds an error with the messages returned by Jira.
+func responseError(method string, path string, resp *http.Response) error {
+	var jiraErr struct {
+		ErrorMessages []string
+		Errors        map[string]string
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&jiraErr)
+
+	messages := jiraErr.ErrorMessages
+	for field, msg := range jiraErr.Errors {
+		messages = append(messages, field+": "+msg)
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("jira: %s %s: %s", method, path, resp.Status)
+	}
+	return fmt.Errorf("jira: %s %s: %s: %s", method, path, resp.Status, strings.Join(messages, ", "))
+}
+
+func issuePath(key string) string {
+	return "/rest/api/2/issue/" + url.PathEscape(key)
+}
@@ -0,0 +1,118 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		token   string
+		wantErr error
+	}{
+		{"cloud", "https://example.atlassian.net", "token", nil},
+		{"server with path", "http://jira.local:8080/jira/", "token", nil},
+		{"missing url", "", "token", ErrNotConfigured},
+		{"missing token", "https://example.atlassian.net", "", ErrNotConfigured},
+		{"without scheme", "example.atlassian.net", "token", ErrInvalidBaseURL},
+		{"relative", "/rest/api", "token", ErrInvalidBaseURL},
+		{"another scheme", "ftp://example.atlassian.net", "token", ErrInvalidBaseURL},
+		{"without host", "https://", "token", ErrInvalidBaseURL},
+		{"unparsable", "https://exa mple.net/%zz", "token", ErrInvalidBaseURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(Config{BaseURL: tt.baseURL, Token: tt.token})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewClient(%q) = %v, want %v", tt.baseURL, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeJira serves an issue and records the story points set.
+type fakeJira struct {
+	t      *testing.T
+	points map[string]interface{}
+	auth   string
+}
+
+func (f *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.auth = r.Header.Get("Authorization")
+
+	switch {
+	case r.URL.Path == "/rest/api/2/issue/PROJ-1" && r.Method == http.MethodGet:
+		if r.URL.Query().Get("fields") != "summary" {
+			f.t.Errorf("fields = %q, want summary", r.URL.Query().Get("fields"))
+		}
+		w.Write([]byte(`{"key": "PROJ-1", "fields": {"summary": "Login page"}}`))
+	case r.URL.Path == "/rest/api/2/issue/PROJ-1" && r.Method == http.MethodPut:
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			f.t.Errorf("content type = %q, want application/json", ct)
+		}
+		var body struct {
+			Fields map[string]interface{}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Error(err)
+		}
+		f.points = body.Fields
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errorMessages": ["Issue does not exist"], "errors": {}}`))
+	}
+}
+
+func TestClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantAuth string
+	}{
+		{"cloud api token", "alice@example.com", "Basic "},
+		{"personal access token", "", "Bearer token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeJira{t: t}
+			srv := httptest.NewServer(f)
+			defer srv.Close()
+
+			c, err := NewClient(Config{BaseURL: srv.URL + "/", Email: tt.email, Token: "token"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issue, err := c.GetIssue(context.Background(), "PROJ-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *issue != (Issue{Key: "PROJ-1", Summary: "Login page"}) {
+				t.Errorf("GetIssue() = %+v", issue)
+			}
+			if !strings.HasPrefix(f.auth, tt.wantAuth) {
+				t.Errorf("authorization = %q, want %q", f.auth, tt.wantAuth)
+			}
+
+			if err := c.SetStoryPoints(context.Background(), "PROJ-1", 5); err != nil {
+				t.Fatal(err)
+			}
+			if f.points[DefaultStoryPointsField] != 5.0 {
+				t.Errorf("fields set = %v, want %s: 5", f.points, DefaultStoryPointsField)
+			}
+
+			_, err = c.GetIssue(context.Background(), "PROJ-2")
+			if err == nil || !strings.Contains(err.Error(), "Issue does not exist") {
+				t.Errorf("GetIssue() of a missing issue = %v, want the jira error", err)
+			}
+		})
+	}
+}
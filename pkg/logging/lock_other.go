@@ -0,0 +1,9 @@
+//go:build !unix
+
+package logging
+
+// lock does nothing where flock isn't available, instances sharing the
+// log file must use -log-file.
+func lock(path string) error {
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package logging
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+)
+
+var (
+	locksMu sync.Mutex
+	// the lock files stay open until the process exits, which releases the
+	// locks even if it crashes
+	locks []*os.File
+)
+
+// lock takes an exclusive lock on the file for the rest of the process.
+func lock(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return ErrInUse
+		}
+		return err
+	}
+
+	locksMu.Lock()
+	locks = append(locks, f)
+	locksMu.Unlock()
+	return nil
+}
@@ -0,0 +1,147 @@
+// Package logging writes the logs of the estimator and of libp2p to a
+// rotating file, so they don't write over the text UI and connectivity
+// issues can be debugged after the fact.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	golog "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultFileName is the name of the log file in the user config dir.
+const DefaultFileName = "estimator.log"
+
+// MaxDefaultFiles is the number of default log files tried when other
+// instances on the same machine use the first ones.
+const MaxDefaultFiles = 32
+
+// DefaultLevel is the level used when none is set.
+const DefaultLevel = "info"
+
+// ErrInvalidLevel is returned when the level isn't one of the known ones.
+var ErrInvalidLevel = errors.New("invalid log level")
+
+// ErrInUse is returned when another running instance writes to the log
+// file.
+var ErrInUse = errors.New("the log file is used by another running instance")
+
+// Config sets where the logs go.
+type Config struct {
+	// File is where the logs are written, it is rotated as it grows.
+	File string
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string
+	// Console also writes the logs to stderr, for the modes without the
+	// text UI.
+	Console bool
+}
+
+var (
+	mu   sync.Mutex
+	core zapcore.Core
+)
+
+// DefaultPath returns the path of the log file in the user config dir.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "p2p-estimator", DefaultFileName), nil
+}
+
+// LockDefault returns the first default log file no other running
+// instance uses: estimator.log, then estimator-2.log and so on. It stays
+// locked while this process runs, so the instances running side by side
+// don't write over each other's logs, and the same files are reused
+// across runs.
+func LockDefault() (string, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(path)
+	base := path[:len(path)-len(ext)]
+
+	for i := 1; i <= MaxDefaultFiles; i++ {
+		p := path
+		if i > 1 {
+			p = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		err := lock(p + ".lock")
+		if errors.Is(err, ErrInUse) {
+			continue
+		}
+		return p, err
+	}
+	return "", ErrInUse
+}
+
+// Setup sends the logs of the estimator, of libp2p and of the standard
+// logger to the file. The file must be closed when leaving.
+func Setup(cfg Config) (io.Closer, error) {
+	level, err := golog.LevelFromString(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidLevel, cfg.Level)
+	}
+	file, err := openRotatingFile(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	cores := []zapcore.Core{
+		zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), file, zapcore.DebugLevel),
+	}
+	if cfg.Console {
+		cores = append(cores, zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.Lock(os.Stderr), zapcore.DebugLevel))
+	}
+
+	// the level of each logger, including the ones libp2p creates later,
+	// is set by go-log, the cores log everything they get
+	golog.SetupLogging(golog.Config{Format: golog.JSONOutput, Level: level})
+	mu.Lock()
+	core = zapcore.NewTee(cores...)
+	golog.SetPrimaryCore(core)
+	mu.Unlock()
+
+	log.SetFlags(0)
+	zap.RedirectStdLog(Logger("std").Desugar())
+	return file, nil
+}
+
+// With adds fields to every log entry from now on, like the ID of the peer
+// and the room, which are only known after the setup. The arguments are
+// pairs of keys and values.
+func With(keysAndValues ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if core == nil {
+		return
+	}
+	fields := make([]zap.Field, 0, len(keysAndValues)/2)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields = append(fields, zap.Any(fmt.Sprint(keysAndValues[i]), keysAndValues[i+1]))
+	}
+	core = core.With(fields)
+	golog.SetPrimaryCore(core)
+}
+
+// Logger returns the logger of a part of the estimator.
+func Logger(name string) *golog.ZapEventLogger {
+	return golog.Logger("estimator/" + name)
+}
@@ -0,0 +1,27 @@
+package logging
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLockDefaultGivesEachInstanceItsFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	first, err := LockDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LockDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(first) != DefaultFileName {
+		t.Errorf("first log in %s, want %s", first, DefaultFileName)
+	}
+	if filepath.Base(second) != "estimator-2.log" {
+		t.Errorf("second log in %s, want estimator-2.log", second)
+	}
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MaxFileSize is the size of the log file before it is rotated.
+const MaxFileSize = 10 << 20
+
+// MaxBackups is the number of rotated log files kept, the one ending in
+// .1 being the newest.
+const MaxBackups = 3
+
+// rotatingFile is a log file that is rotated when it grows past
+// MaxFileSize. It is safe for concurrent use.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > MaxFileSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the log file to the first backup, shifting the older
+// ones and dropping the oldest, and starts a new file. When that fails we
+// keep writing to the file we had, rotating again on the next write.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	for i := MaxBackups - 1; i > 0; i-- {
+		// backups that don't exist yet are fine
+		_ = os.Rename(backup(r.path, i), backup(r.path, i+1))
+	}
+	if err := os.Rename(r.path, backup(r.path, 1)); err != nil {
+		return r.open()
+	}
+	if err := r.open(); err != nil {
+		if os.Rename(backup(r.path, 1), r.path) != nil || r.open() != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Sync()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+func backup(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
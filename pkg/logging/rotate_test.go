@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "estimator.log")
+	r, err := openRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.Write([]byte("old\n")); err != nil {
+		t.Fatal(err)
+	}
+	r.size = MaxFileSize
+	if _, err := r.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string]string{path: "new\n", backup(path, 1): "old\n"} {
+		got, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestRotateFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "estimator.log")
+	// backups that can't be replaced, as they are directories with files
+	for i := 1; i <= MaxBackups; i++ {
+		if err := os.MkdirAll(filepath.Join(backup(path, i), "file"), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := openRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.Write([]byte("old\n")); err != nil {
+		t.Fatal(err)
+	}
+	r.size = MaxFileSize
+	if _, err := r.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "old\nnew\n"; string(got) != want {
+		t.Errorf("%s = %q, want %q", path, got, want)
+	}
+}
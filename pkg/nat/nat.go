@@ -0,0 +1,128 @@
+package nat
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Status describes how other peers can reach this host.
+type Status string
+
+const (
+	Unknown Status = "checking connectivity"
+	Direct  Status = "directly reachable"
+	Relayed Status = "reachable through a relay"
+	Private Status = "behind a NAT, not reachable"
+)
+
+// Config holds the NAT traversal settings of a host.
+type Config struct {
+	// Relays are the circuit relays used to reach this host when it isn't
+	// directly reachable.
+	Relays []peer.AddrInfo
+
+	// RelayService makes this host act as a relay for other peers.
+	RelayService bool
+
+	// ForceReachability skips AutoNAT and assumes the given reachability.
+	// It allows to simulate NATs with several hosts running locally.
+	ForceReachability network.Reachability
+}
+
+// Options returns the libp2p options to setup NAT traversal: port mapping,
+// AutoNAT, relay v2 (client and optionally service) and DCUtR hole punching.
+func Options(cfg Config) []libp2p.Option {
+	options := []libp2p.Option{
+		libp2p.NATPortMap(),
+		libp2p.EnableNATService(),
+		libp2p.EnableHolePunching(),
+	}
+
+	if len(cfg.Relays) > 0 {
+		options = append(options, libp2p.EnableAutoRelay(autorelay.WithStaticRelays(cfg.Relays)))
+	}
+
+	if cfg.RelayService {
+		options = append(options, libp2p.EnableRelayService())
+	}
+
+	switch cfg.ForceReachability {
+	case network.ReachabilityPublic:
+		options = append(options, libp2p.ForceReachabilityPublic())
+	case network.ReachabilityPrivate:
+		options = append(options, libp2p.ForceReachabilityPrivate())
+	}
+
+	return options
+}
+
+// WatchStatus sends the connectivity status of the host every time its
+// reachability or addresses change. The channel is closed when the context
+// is done.
+func WatchStatus(ctx context.Context, h host.Host) (<-chan Status, error) {
+	sub, err := h.EventBus().Subscribe([]interface{}{
+		new(event.EvtLocalReachabilityChanged),
+		new(event.EvtLocalAddressesUpdated),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(chan Status, 1)
+	go func() {
+		defer sub.Close()
+		defer close(statuses)
+
+		reachability := network.ReachabilityUnknown
+		last := Status("")
+		for {
+			s := status(reachability, h.Addrs())
+			if s != last {
+				select {
+				case statuses <- s:
+					last = s
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				if evt, ok := e.(event.EvtLocalReachabilityChanged); ok {
+					reachability = evt.Reachability
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return statuses, nil
+}
+
+func status(reachability network.Reachability, addrs []multiaddr.Multiaddr) Status {
+	if reachability == network.ReachabilityPublic {
+		return Direct
+	}
+
+	for _, addr := range addrs {
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			return Relayed
+		}
+	}
+
+	if reachability == network.ReachabilityPrivate {
+		return Private
+	}
+	return Unknown
+}
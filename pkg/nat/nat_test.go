@@ -0,0 +1,116 @@
+package nat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func newHost(t *testing.T, cfg Config) host.Host {
+	t.Helper()
+
+	options := append([]libp2p.Option{libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0")}, Options(cfg)...)
+	h, err := libp2p.New(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// waitStatus waits until the host reports the status.
+func waitStatus(t *testing.T, ctx context.Context, h host.Host, want Status) {
+	t.Helper()
+
+	statuses, err := WatchStatus(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last Status
+	for s := range statuses {
+		if s == want {
+			return
+		}
+		last = s
+	}
+	t.Fatalf("status of %s is %q, want %q", h.ID().ShortString(), last, want)
+}
+
+func TestRelayedPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	relay := newHost(t, Config{
+		RelayService:      true,
+		ForceReachability: network.ReachabilityPublic,
+	})
+	// autorelay ignores loopback addresses of relays, but not DNS ones
+	port, err := relay.Addrs()[0].ValueForProtocol(multiaddr.P_TCP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayInfo := peer.AddrInfo{
+		ID:    relay.ID(),
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/dns4/localhost/tcp/" + port)},
+	}
+	alice := newHost(t, Config{
+		Relays:            []peer.AddrInfo{relayInfo},
+		ForceReachability: network.ReachabilityPrivate,
+	})
+	bob := newHost(t, Config{
+		Relays:            []peer.AddrInfo{relayInfo},
+		ForceReachability: network.ReachabilityPrivate,
+	})
+
+	waitStatus(t, ctx, relay, Direct)
+	waitStatus(t, ctx, alice, Relayed)
+	waitStatus(t, ctx, bob, Relayed)
+
+	// bob reaches alice through the relay, with only her circuit address
+	var circuit []multiaddr.Multiaddr
+	for _, addr := range alice.Addrs() {
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			circuit = append(circuit, addr)
+		}
+	}
+	if err := bob.Connect(ctx, peer.AddrInfo{ID: alice.ID(), Addrs: circuit}); err != nil {
+		t.Fatalf("bob can't reach alice through the relay: %s", err)
+	}
+	conns := bob.Network().ConnsToPeer(alice.ID())
+	if len(conns) == 0 {
+		t.Fatal("bob isn't connected to alice")
+	}
+	if _, err := conns[0].RemoteMultiaddr().ValueForProtocol(multiaddr.P_CIRCUIT); err != nil {
+		t.Errorf("bob is connected to alice on %s, want a relayed connection", conns[0].RemoteMultiaddr())
+	}
+}
+
+func TestStatus(t *testing.T) {
+	direct := multiaddr.StringCast("/ip4/1.2.3.4/tcp/4001")
+	relayed := multiaddr.StringCast("/ip4/1.2.3.4/tcp/4001/p2p/12D3KooWCMqh1hqs5ZnVRRBt4dj18nwx6RiVy8pxYHdpUs2WumMK/p2p-circuit")
+
+	tests := []struct {
+		name         string
+		reachability network.Reachability
+		addrs        []multiaddr.Multiaddr
+		want         Status
+	}{
+		{"unknown", network.ReachabilityUnknown, []multiaddr.Multiaddr{direct}, Unknown},
+		{"public", network.ReachabilityPublic, []multiaddr.Multiaddr{direct, relayed}, Direct},
+		{"private", network.ReachabilityPrivate, []multiaddr.Multiaddr{direct}, Private},
+		{"relayed", network.ReachabilityPrivate, []multiaddr.Multiaddr{direct, relayed}, Relayed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status(tt.reachability, tt.addrs); got != tt.want {
+				t.Errorf("status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
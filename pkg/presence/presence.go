@@ -0,0 +1,170 @@
+// Package presence tracks who is in the room. Peers join and leave as
+// pubsub sees them subscribe and unsubscribe from the room topic, and they
+// are away when they stop sending messages for a while, which tells apart
+// peers whose connection is lingering from the ones still there.
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Interval is how often peers send a liveness signal when they have
+// nothing else to say.
+const Interval = 5 * time.Second
+
+// AwayAfter is for how long a peer can be silent before it is away.
+const AwayAfter = 3 * Interval
+
+// LeaveAfter is for how long a peer can be silent before it is considered
+// gone, even if pubsub didn't see it leave.
+const LeaveAfter = 12 * Interval
+
+// Status of a peer in the room.
+type Status string
+
+const (
+	Online Status = "online"
+	Away   Status = "away"
+	Left   Status = "left"
+)
+
+// Peer is a peer seen in the room.
+type Peer struct {
+	ID       peer.ID
+	Nick     string
+	Status   Status
+	JoinedAt time.Time
+	LastSeen time.Time
+}
+
+type entry struct {
+	nick     string
+	joinedAt time.Time
+	lastSeen time.Time
+	left     bool
+}
+
+// Tracker keeps the presence of the peers of a room. It is safe for
+// concurrent use.
+type Tracker struct {
+	mu    sync.Mutex
+	peers map[peer.ID]*entry
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		peers: map[peer.ID]*entry{},
+	}
+}
+
+// Joined records a peer that subscribed to the room.
+func (t *Tracker) Joined(id peer.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.touch(id)
+}
+
+// Left records a peer that unsubscribed from the room.
+func (t *Tracker) Left(id peer.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.peers[id]; ok {
+		e.left = true
+	}
+}
+
+// Seen records a message from a peer, which is alive.
+func (t *Tracker) Seen(id peer.ID, nick string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.touch(id)
+	if nick != "" {
+		e.nick = nick
+	}
+}
+
+// touch marks the peer as alive, rejoining it if it had left.
+func (t *Tracker) touch(id peer.ID) *entry {
+	now := time.Now()
+	e, ok := t.peers[id]
+	if !ok || e.left || now.Sub(e.lastSeen) > LeaveAfter {
+		if !ok {
+			e = &entry{}
+			t.peers[id] = e
+		}
+		e.joinedAt = now
+		e.left = false
+	}
+	e.lastSeen = now
+	return e
+}
+
+// Peer returns the presence of a peer, if it was ever seen.
+func (t *Tracker) Peer(id peer.ID) (Peer, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.peers[id]
+	if !ok {
+		return Peer{}, false
+	}
+	return t.peer(id, e), true
+}
+
+// Peers returns the presence of the peers ever seen, sorted by nick.
+func (t *Tracker) Peers() []Peer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	peers := make([]Peer, 0, len(t.peers))
+	for id, e := range t.peers {
+		peers = append(peers, t.peer(id, e))
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Nick < peers[j].Nick
+	})
+	return peers
+}
+
+// Recent returns the peers seen in the last d, including the ones that
+// left, so we can try to reach them again after losing the connection.
+func (t *Tracker) Recent(d time.Duration) []peer.ID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var ids []peer.ID
+	for id, e := range t.peers {
+		if time.Since(e.lastSeen) <= d {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// peer computes the status of the peer from the time it was last seen, so
+// it doesn't depend on how often it is asked.
+func (t *Tracker) peer(id peer.ID, e *entry) Peer {
+	p := Peer{
+		ID:       id,
+		Nick:     e.nick,
+		Status:   Online,
+		JoinedAt: e.joinedAt,
+		LastSeen: e.lastSeen,
+	}
+	silence := time.Since(e.lastSeen)
+	switch {
+	case e.left || silence > LeaveAfter:
+		p.Status = Left
+	case silence > AwayAfter:
+		p.Status = Away
+	}
+	return p
+}
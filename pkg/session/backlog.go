@@ -0,0 +1,125 @@
+package session
+
+import (
+	"errors"
+
+	"github.com/renato0307/p2p-estimator/pkg/backlog"
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+)
+
+// ErrNoStory is returned when moving past the ends of the backlog.
+var ErrNoStory = errors.New("there are no more stories in that direction")
+
+// backlogMessage replicates the backlog loaded by a participant.
+type backlogMessage struct {
+	Stories []backlog.Story
+}
+
+// moveMessage is sent when the room moves to another story. It carries the
+// estimate of the story left, if it was estimated.
+type moveMessage struct {
+	Left     int
+	Estimate string
+	Current  int
+}
+
+// LoadBacklog replaces the backlog of the room and starts estimating its
+// first story.
+func (s *EstimationSession) LoadBacklog(stories []backlog.Story) error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	if len(stories) == 0 {
+		return backlog.ErrEmpty
+	}
+	if !s.isFacilitator() {
+		return s.request(ActionLoadBacklog)
+	}
+
+	if err := s.pub.Publish(chatroom.SetBacklog, s.round+1, backlogMessage{Stories: stories}); err != nil {
+		return err
+	}
+	s.round++
+	s.setBacklog(stories)
+	return nil
+}
+
+// NextStory saves the estimate of the current story, if the votes were
+// revealed, and moves to the next one.
+func (s *EstimationSession) NextStory() error {
+	return s.move(1, true)
+}
+
+// PreviousStory saves the estimate of the current story, if the votes were
+// revealed, and moves to the previous one.
+func (s *EstimationSession) PreviousStory() error {
+	return s.move(-1, true)
+}
+
+// SkipStory moves to the next story without saving an estimate.
+func (s *EstimationSession) SkipStory() error {
+	return s.move(1, false)
+}
+
+func (s *EstimationSession) move(step int, keepEstimate bool) error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	next := s.current + step
+	if next < 0 || next >= len(s.backlog) {
+		return ErrNoStory
+	}
+	if !s.isFacilitator() {
+		return s.request(ActionMoveStory)
+	}
+
+	mm := moveMessage{Left: s.current, Current: next}
+	if keepEstimate && s.revealed {
+		mm.Estimate, _ = s.estimate()
+	}
+	if err := s.pub.Publish(chatroom.MoveStory, s.round+1, mm); err != nil {
+		return err
+	}
+	s.round++
+	s.moveTo(mm)
+	return nil
+}
+
+func (s *EstimationSession) changeBacklog(msg *chatroom.ChatMessage) {
+	var bm backlogMessage
+	if err := msg.Decode(&bm); err != nil || len(bm.Stories) == 0 {
+		return
+	}
+	s.round = s.nextRound(msg.Round)
+	s.setBacklog(bm.Stories)
+}
+
+func (s *EstimationSession) changeStory(msg *chatroom.ChatMessage) {
+	var mm moveMessage
+	if err := msg.Decode(&mm); err != nil {
+		return
+	}
+	if mm.Current < 0 || mm.Current >= len(s.backlog) {
+		return
+	}
+	s.round = s.nextRound(msg.Round)
+	s.moveTo(mm)
+}
+
+func (s *EstimationSession) setBacklog(stories []backlog.Story) {
+	s.backlog = stories
+	s.current = 0
+	s.clearVotes()
+	s.resetRounds()
+	s.description = stories[0].String()
+}
+
+func (s *EstimationSession) moveTo(mm moveMessage) {
+	if mm.Estimate != "" && mm.Left >= 0 && mm.Left < len(s.backlog) {
+		s.backlog[mm.Left].Estimate = mm.Estimate
+	}
+	s.current = mm.Current
+	s.clearVotes()
+	s.resetRounds()
+	s.description = s.backlog[s.current].String()
+}
@@ -0,0 +1,39 @@
+package session
+
+import (
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+)
+
+// deckMessage is sent when the room changes deck. Votes from the previous
+// deck can't be compared, so a new round starts.
+type deckMessage struct {
+	Deck deck.Deck
+}
+
+// SetDeck changes the deck used by the room and starts a new round.
+func (s *EstimationSession) SetDeck(d deck.Deck) error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	if !s.isFacilitator() {
+		return s.request(ActionSetDeck)
+	}
+	if err := s.pub.Publish(chatroom.SetDeck, s.round+1, deckMessage{Deck: d}); err != nil {
+		return err
+	}
+	s.clearVotes()
+	s.round++
+	s.deck = d
+	return nil
+}
+
+func (s *EstimationSession) changeDeck(msg *chatroom.ChatMessage) {
+	var dm deckMessage
+	if err := msg.Decode(&dm); err != nil || len(dm.Deck.Cards) == 0 {
+		return
+	}
+	s.clearVotes()
+	s.round = s.nextRound(msg.Round)
+	s.deck = dm.Deck
+}
@@ -0,0 +1,153 @@
+package session
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// EventType tells what changed in the room.
+type EventType string
+
+const (
+	EventJoined      EventType = "joined"
+	EventLeft        EventType = "left"
+	EventAway        EventType = "away"
+	EventBack        EventType = "back"
+	EventVoted       EventType = "voted"
+	EventRevealed    EventType = "revealed"
+	EventVoteOpened  EventType = "vote-opened"
+	EventNewRound    EventType = "new-round"
+	EventDescription EventType = "description"
+	EventDeck        EventType = "deck"
+	EventStory       EventType = "story"
+	EventFacilitator EventType = "facilitator"
+	EventRequest     EventType = "request"
+)
+
+// Event is a change in the room, for programs that follow the room
+// without the text UI. Only the fields that matter to each type are set.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	Peer peer.ID `json:"peer,omitempty"`
+	Nick string  `json:"nick,omitempty"`
+	// Vote is set when the vote of a participant is known after the
+	// reveal.
+	Vote string `json:"vote,omitempty"`
+
+	Description string `json:"description,omitempty"`
+	Deck        string `json:"deck,omitempty"`
+	// Round is the round of votes of the story.
+	Round int `json:"round,omitempty"`
+	// Story is the position of the story in the backlog, starting at 1.
+	Story int `json:"story,omitempty"`
+	// Action is what a participant asked the facilitator.
+	Action string `json:"action,omitempty"`
+
+	// Votes and Statistics are set when the votes are revealed. Votes are
+	// keyed by peer ID, as nicks aren't unique.
+	Votes      map[peer.ID]EventVote `json:"votes,omitempty"`
+	Statistics *Statistics           `json:"statistics,omitempty"`
+}
+
+// EventVote is the vote of a participant when the votes are revealed.
+type EventVote struct {
+	Nick string `json:"nick"`
+	Vote string `json:"vote"`
+}
+
+// Changes returns the events that took the room from the prev state to the
+// next one.
+func Changes(prev, next State) []Event {
+	now := time.Now()
+	var events []Event
+	add := func(e Event) {
+		e.Time = now
+		events = append(events, e)
+	}
+
+	before := map[peer.ID]Participant{}
+	for _, p := range prev.Participants {
+		before[p.ID] = p
+	}
+	after := map[peer.ID]Participant{}
+	for _, p := range next.Participants {
+		after[p.ID] = p
+	}
+
+	for _, p := range next.Participants {
+		if _, ok := before[p.ID]; !ok && !p.Self {
+			add(Event{Type: EventJoined, Peer: p.ID, Nick: p.Nick})
+		}
+	}
+	for _, p := range prev.Participants {
+		if _, ok := after[p.ID]; !ok {
+			add(Event{Type: EventLeft, Peer: p.ID, Nick: p.Nick})
+		}
+	}
+	for _, p := range next.Participants {
+		b, ok := before[p.ID]
+		switch {
+		case ok && p.Away && !b.Away:
+			add(Event{Type: EventAway, Peer: p.ID, Nick: p.Nick})
+		case ok && !p.Away && b.Away:
+			add(Event{Type: EventBack, Peer: p.ID, Nick: p.Nick})
+		}
+	}
+
+	if next.Deck.Name != prev.Deck.Name {
+		add(Event{Type: EventDeck, Deck: next.Deck.Name})
+	}
+	if next.Current != prev.Current || len(next.Backlog) != len(prev.Backlog) {
+		if len(next.Backlog) > 0 {
+			add(Event{Type: EventStory, Story: next.Current + 1, Description: next.Description})
+		}
+	}
+	if next.Description != prev.Description {
+		add(Event{Type: EventDescription, Description: next.Description})
+	}
+	if next.Facilitator != prev.Facilitator && next.Facilitator != "" {
+		add(Event{Type: EventFacilitator, Peer: next.Facilitator, Nick: after[next.Facilitator].Nick})
+	}
+	if len(next.Requests) > len(prev.Requests) || requestsChanged(prev.Requests, next.Requests) {
+		r := next.Requests[len(next.Requests)-1]
+		add(Event{Type: EventRequest, Peer: r.From, Nick: r.Nick, Action: r.Action})
+	}
+
+	if next.Round != prev.Round && !next.Revealed {
+		add(Event{Type: EventNewRound, Round: next.StoryRound, Description: next.Description})
+	}
+	for _, p := range next.Participants {
+		if p.Voted && (!before[p.ID].Voted || next.Round != prev.Round) {
+			add(Event{Type: EventVoted, Peer: p.ID, Nick: p.Nick})
+		}
+	}
+	if next.Revealed && (!prev.Revealed || next.Round != prev.Round) {
+		e := Event{Type: EventRevealed, Round: next.StoryRound, Votes: map[peer.ID]EventVote{}, Statistics: next.Statistics}
+		for _, p := range next.Participants {
+			if p.Voted {
+				e.Votes[p.ID] = EventVote{Nick: p.Nick, Vote: p.Vote}
+			}
+		}
+		add(e)
+	} else if next.Revealed {
+		// the votes of the peers are known as their openings arrive
+		for _, p := range next.Participants {
+			if p.Vote != "" && p.Vote != before[p.ID].Vote {
+				add(Event{Type: EventVoteOpened, Peer: p.ID, Nick: p.Nick, Vote: p.Vote})
+			}
+		}
+	}
+	return events
+}
+
+// requestsChanged tells if a new request replaced an old one, once the
+// facilitator has MaxRequests of them.
+func requestsChanged(prev, next []Request) bool {
+	if len(next) == 0 || len(prev) == 0 {
+		return false
+	}
+	return !next[len(next)-1].At.Equal(prev[len(prev)-1].At)
+}
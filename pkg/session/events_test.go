@@ -0,0 +1,41 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestChangesRevealed(t *testing.T) {
+	alice, bob, otherBob := newTestID(t), newTestID(t), newTestID(t)
+	prev := State{
+		Participants: []Participant{
+			{ID: alice, Nick: "alice", Voted: true, Vote: "3", Self: true},
+			{ID: bob, Nick: "bob", Voted: true},
+			{ID: otherBob, Nick: "bob", Voted: true},
+		},
+	}
+	next := State{
+		Revealed:   true,
+		StoryRound: 1,
+		Participants: []Participant{
+			{ID: alice, Nick: "alice", Voted: true, Vote: "3", Self: true},
+			{ID: bob, Nick: "bob", Voted: true, Vote: "5"},
+			{ID: otherBob, Nick: "bob", Voted: true, Vote: "8"},
+		},
+	}
+
+	events := Changes(prev, next)
+	if len(events) != 1 || events[0].Type != EventRevealed {
+		t.Fatalf("Changes() = %+v, want a revealed event", events)
+	}
+	want := map[peer.ID]EventVote{
+		alice:    {Nick: "alice", Vote: "3"},
+		bob:      {Nick: "bob", Vote: "5"},
+		otherBob: {Nick: "bob", Vote: "8"},
+	}
+	if !reflect.DeepEqual(events[0].Votes, want) {
+		t.Errorf("votes revealed = %v, want %v", events[0].Votes, want)
+	}
+}
@@ -0,0 +1,217 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// MaxRequests is the number of requests kept for the facilitator.
+const MaxRequests = 5
+
+var (
+	// ErrRequested is returned when a participant tries an action only the
+	// facilitator can do. The action is sent to the facilitator as a request.
+	ErrRequested = errors.New("only the facilitator can do it, a request was sent to them")
+	// ErrNotFacilitator is returned when handing over a role we don't have.
+	ErrNotFacilitator = errors.New("only the facilitator can hand over the role")
+)
+
+// Actions only the facilitator can do.
+const (
+	ActionReveal         = "reveal the votes"
+	ActionClear          = "clear the votes"
+	ActionSetDescription = "set the description"
+	ActionSetDeck        = "change the deck"
+	ActionLoadBacklog    = "load a backlog"
+	ActionMoveStory      = "move to another story"
+	ActionRevote         = "vote again"
+)
+
+// Request is an action a participant asked the facilitator to do.
+type Request struct {
+	From   peer.ID
+	Nick   string
+	Action string
+	At     time.Time
+}
+
+// facilitatorMessage announces who holds the facilitator role. Since is
+// informative only, the peers decide who holds the role with what they saw
+// themselves, see acceptFacilitator.
+type facilitatorMessage struct {
+	Facilitator string
+	Term        int
+	Since       time.Time
+}
+
+// HandOver gives the facilitator role to another participant.
+func (s *EstimationSession) HandOver(id peer.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.facilitator != s.self {
+		return ErrNotFacilitator
+	}
+	return s.announceFacilitator(id, s.facilitatorTerm+1)
+}
+
+// isFacilitator tells if we can do the actions reserved to the facilitator.
+// Until the room has a facilitator everyone can.
+func (s *EstimationSession) isFacilitator() bool {
+	return s.facilitator == "" || s.facilitator == s.self
+}
+
+// fromFacilitator tells if a control message must be obeyed.
+func (s *EstimationSession) fromFacilitator(id peer.ID) bool {
+	return s.facilitator == "" || s.facilitator == id
+}
+
+// request sends an action to the facilitator.
+func (s *EstimationSession) request(action string) error {
+	if err := s.pub.Publish(chatroom.RequestControl, s.round, chatroom.RequestControlPayload{Action: action}); err != nil {
+		return err
+	}
+	return ErrRequested
+}
+
+// addRequest keeps the requests sent to us while we are the facilitator.
+func (s *EstimationSession) addRequest(from peer.ID, action string) {
+	if s.facilitator != s.self {
+		return
+	}
+	nick := ""
+	if p, ok := s.participants[from]; ok {
+		nick = p.nick
+	}
+	s.requests = append(s.requests, Request{
+		From:   from,
+		Nick:   nick,
+		Action: action,
+		At:     time.Now(),
+	})
+	if len(s.requests) > MaxRequests {
+		s.requests = s.requests[len(s.requests)-MaxRequests:]
+	}
+}
+
+// claimFacilitator makes us the facilitator if, after the time to sync
+// with the room, nobody has the role. The room creator ends up with it.
+func (s *EstimationSession) claimFacilitator() error {
+	if s.facilitator != "" || time.Now().Before(s.claimDeadline) {
+		return nil
+	}
+	return s.announceFacilitator(s.self, s.facilitatorTerm)
+}
+
+// failover elects a new facilitator when the current one left the room.
+// Every peer elects the participant with the lowest ID, so they agree
+// without talking, and the elected one announces it.
+func (s *EstimationSession) failover() error {
+	elected := s.self
+	for id := range s.participants {
+		if id < elected {
+			elected = id
+		}
+	}
+	s.facilitatorTerm++
+	s.facilitator = elected
+	s.facilitatorSince = time.Now()
+	s.requests = nil
+	if elected != s.self {
+		return nil
+	}
+	return s.announceFacilitator(s.self, s.facilitatorTerm)
+}
+
+func (s *EstimationSession) announceFacilitator(id peer.ID, term int) error {
+	fm := facilitatorMessage{
+		Facilitator: id.Pretty(),
+		Term:        term,
+		Since:       time.Now(),
+	}
+	if err := s.pub.Publish(chatroom.SetFacilitator, s.round, fm); err != nil {
+		return err
+	}
+	s.setFacilitator(&fm)
+	return nil
+}
+
+// changeFacilitator handles announcements from other peers: claims, hand
+// overs from the current facilitator and failovers. When we are the
+// facilitator and reject a claim, we announce it again so the claimant
+// steps down.
+func (s *EstimationSession) changeFacilitator(from peer.ID, msg *chatroom.ChatMessage) error {
+	fm := new(facilitatorMessage)
+	if err := msg.Decode(fm); err != nil {
+		return nil
+	}
+	id, err := peer.Decode(fm.Facilitator)
+	if err != nil {
+		return nil
+	}
+
+	if s.acceptFacilitator(from, id, fm.Term) {
+		s.setFacilitator(fm)
+		return nil
+	}
+
+	if s.facilitator != s.self {
+		return nil
+	}
+	return s.pub.Publish(chatroom.SetFacilitator, s.round, s.currentFacilitator())
+}
+
+// acceptFacilitator tells if from can make id the facilitator. The current
+// facilitator can hand the role over to anyone, while the other peers can
+// only claim it for themselves once the facilitator left, as far as we can
+// tell. Claims made at the same time, when a room starts or after a
+// failover, are won by the lowest peer ID.
+func (s *EstimationSession) acceptFacilitator(from peer.ID, id peer.ID, term int) bool {
+	switch {
+	case term < s.facilitatorTerm:
+		return false
+	case from == s.facilitator:
+		return true
+	case from != id:
+		return false
+	case s.facilitator == "" || s.facilitatorLeft():
+		return true
+	}
+	contested := term == s.facilitatorTerm && time.Since(s.facilitatorSince) < SyncWindow
+	return contested && id < s.facilitator
+}
+
+// facilitatorLeft tells if the facilitator left the room, as we do for
+// the other participants. A facilitator we never heard from is given the
+// same time to show up.
+func (s *EstimationSession) facilitatorLeft() bool {
+	if s.facilitator == s.self {
+		return false
+	}
+	return s.silent(s.facilitator, s.facilitatorSince)
+}
+
+func (s *EstimationSession) currentFacilitator() *facilitatorMessage {
+	return &facilitatorMessage{
+		Facilitator: s.facilitator.Pretty(),
+		Term:        s.facilitatorTerm,
+		Since:       s.facilitatorSince,
+	}
+}
+
+func (s *EstimationSession) setFacilitator(fm *facilitatorMessage) {
+	id, err := peer.Decode(fm.Facilitator)
+	if err != nil {
+		return
+	}
+	if id != s.facilitator {
+		s.requests = nil
+	}
+	s.facilitator = id
+	s.facilitatorTerm = fm.Term
+	s.facilitatorSince = time.Now()
+}
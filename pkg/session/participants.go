@@ -0,0 +1,110 @@
+package session
+
+import (
+	"strings"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/presence"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Presence tells who is in the room. It is implemented by
+// presence.Tracker.
+type Presence interface {
+	Peer(id peer.ID) (presence.Peer, bool)
+}
+
+type participant struct {
+	id             peer.ID
+	nick           string
+	vote           string
+	commitment     string
+	opening        string
+	pendingOpening string
+	mismatch       bool
+	addedAt        time.Time
+}
+
+// Tick must be called periodically, how often doesn't matter as long as it
+// is more often than presence.Interval. It sends the liveness signal,
+// restores our state after a reconnection, removes the participants that
+// left and elects a new facilitator when needed.
+func (s *EstimationSession) Tick() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.heartbeatSent) >= presence.Interval {
+		s.heartbeatSent = time.Now()
+		if err := s.pub.Publish(chatroom.Heartbeat, s.round, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkReconnected(); err != nil {
+		return err
+	}
+
+	facilitatorLeft := false
+	for id, p := range s.participants {
+		if id == s.self || !s.left(p) {
+			continue
+		}
+		delete(s.participants, id)
+		facilitatorLeft = facilitatorLeft || id == s.facilitator
+	}
+
+	if facilitatorLeft {
+		return s.failover()
+	}
+	return s.claimFacilitator()
+}
+
+// left tells if a participant left the room, after being silent for
+// presence.LeaveAfter. Pubsub telling that the peer left isn't enough, as
+// it only sees the peers we are connected to, which can still reach the
+// room through others. Participants we only know from the state sent by
+// others are kept until the tracker hears from them or they stay silent
+// for too long.
+func (s *EstimationSession) left(p *participant) bool {
+	return s.silent(p.id, p.addedAt)
+}
+
+// silent tells if we didn't hear from the peer for presence.LeaveAfter,
+// counting since known when the tracker never heard from it.
+func (s *EstimationSession) silent(id peer.ID, known time.Time) bool {
+	pp, ok := s.presence.Peer(id)
+	if !ok {
+		return time.Since(known) > presence.LeaveAfter
+	}
+	return time.Since(pp.LastSeen) > presence.LeaveAfter
+}
+
+// updateParticipant adds the participant if it isn't known yet, which is
+// the only way participants are added, and updates its nick.
+func (s *EstimationSession) updateParticipant(id peer.ID, nick string) *participant {
+	p, ok := s.participants[id]
+	if !ok {
+		p = &participant{id: id, addedAt: time.Now()}
+		s.participants[id] = p
+	}
+	if nick != "" {
+		p.nick = nick
+	}
+	return p
+}
+
+// ParticipantByNick finds one of the other participants by nick, ignoring
+// the case.
+func (s *EstimationSession) ParticipantByNick(nick string) (Participant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.participantList() {
+		if !p.Self && strings.EqualFold(p.Nick, nick) {
+			return p, true
+		}
+	}
+	return Participant{}, false
+}
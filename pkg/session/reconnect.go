@@ -0,0 +1,54 @@
+package session
+
+import (
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+)
+
+// Reconnector tells how many times the room reconnected after losing its
+// peers. It is implemented by chatroom.ChatRoom; publishers that don't
+// implement it never reconnect.
+type Reconnector interface {
+	Reconnections() int
+}
+
+// checkReconnected restores our state in the room when it reconnected
+// since the last check.
+func (s *EstimationSession) checkReconnected() error {
+	r, ok := s.pub.(Reconnector)
+	if !ok {
+		return nil
+	}
+	n := r.Reconnections()
+	if n == s.reconnections {
+		return nil
+	}
+	s.reconnections = n
+	return s.rejoin()
+}
+
+// rejoin catches up with what happened while we were away and tells the
+// room about our vote again, since the peers may have dropped us.
+func (s *EstimationSession) rejoin() error {
+	s.syncRequested = false
+	if err := s.requestState(); err != nil {
+		return err
+	}
+
+	if c := s.participants[s.self].commitment; c != "" {
+		err := s.pub.Publish(chatroom.SendVote, s.round, chatroom.VotePayload{Commitment: c})
+		if err != nil {
+			return err
+		}
+		if s.revealed {
+			s.openingSent = false
+			if err := s.sendOpening(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.isFacilitator() {
+		return s.pub.Publish(chatroom.SetFacilitator, s.round, s.currentFacilitator())
+	}
+	return nil
+}
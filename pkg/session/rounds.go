@@ -0,0 +1,163 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+)
+
+// ErrNotRevealed is returned when voting again before the reveal.
+var ErrNotRevealed = errors.New("reveal the votes before voting again")
+
+// RoundResult is a round whose votes were revealed.
+type RoundResult struct {
+	Story string
+	// Round is the number of the round of votes of the story.
+	Round      int
+	RevealedAt time.Time
+	// Votes has the participants that voted in the round.
+	Votes []Participant
+	// Estimate is the card nearest to the average of the votes.
+	Estimate string
+}
+
+// OnRoundCompleted sets the function called when a revealed round ends,
+// when votes are cleared or the room moves to another story. It is called
+// after the session is unlocked, so it can use the session.
+func (s *EstimationSession) OnRoundCompleted(f func(RoundResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recorder = f
+}
+
+// CompleteRound ends the current round if it was revealed, without
+// starting a new one. It must be called before leaving the room so the last
+// round isn't lost.
+func (s *EstimationSession) CompleteRound() {
+	s.mu.Lock()
+	defer s.unlock()
+
+	s.completeRound()
+}
+
+// unlock unlocks the session and then sends the rounds completed while it
+// was locked to the recorder, which could deadlock otherwise. Methods that
+// can complete a round unlock with it.
+func (s *EstimationSession) unlock() {
+	completed, recorder := s.completed, s.recorder
+	s.completed = nil
+	s.mu.Unlock()
+
+	for _, result := range completed {
+		recorder(result)
+	}
+}
+
+func (s *EstimationSession) completeRound() {
+	if !s.revealed || s.recorded || s.recorder == nil {
+		return
+	}
+	s.recorded = true
+
+	result := RoundResult{
+		Story:      s.description,
+		Round:      s.storyRound,
+		RevealedAt: s.revealedAt,
+	}
+	result.Estimate, _ = s.estimate()
+	for _, p := range s.participantList() {
+		if p.Voted {
+			result.Votes = append(result.Votes, p)
+		}
+	}
+	s.completed = append(s.completed, result)
+}
+
+// StoryRound is a round of votes of the story being estimated that was
+// revealed before the room voted again.
+type StoryRound struct {
+	Number int
+	// Votes has the participants that voted in the round.
+	Votes []Participant
+	// Statistics is nil when nobody played a card with a value.
+	Statistics *Statistics
+}
+
+// revoteMessage starts a new round of votes for the same story.
+type revoteMessage struct {
+	StoryRound int
+}
+
+// Revote keeps the votes revealed as a past round of the story and starts
+// a new round to vote again.
+func (s *EstimationSession) Revote() error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	if !s.revealed {
+		return ErrNotRevealed
+	}
+	if !s.isFacilitator() {
+		return s.request(ActionRevote)
+	}
+
+	rm := revoteMessage{StoryRound: s.storyRound + 1}
+	if err := s.pub.Publish(chatroom.Revote, s.round+1, rm); err != nil {
+		return err
+	}
+	s.round++
+	s.revote(rm)
+	return nil
+}
+
+func (s *EstimationSession) startRevote(msg *chatroom.ChatMessage) {
+	var rm revoteMessage
+	if err := msg.Decode(&rm); err != nil {
+		return
+	}
+	s.round = s.nextRound(msg.Round)
+	s.revote(rm)
+}
+
+func (s *EstimationSession) revote(rm revoteMessage) {
+	if s.revealed {
+		s.archiveRound()
+	}
+	s.clearVotes()
+	if rm.StoryRound > s.storyRound {
+		s.storyRound = rm.StoryRound
+	} else {
+		s.storyRound++
+	}
+}
+
+// archiveRound keeps the votes revealed in the past rounds of the story.
+func (s *EstimationSession) archiveRound() {
+	sr := StoryRound{Number: s.storyRound}
+	for _, p := range s.participantList() {
+		if p.Voted {
+			sr.Votes = append(sr.Votes, p)
+		}
+	}
+	if st, ok := s.statistics(); ok {
+		sr.Statistics = &st
+	}
+	s.pastRounds = append(s.pastRounds, sr)
+}
+
+// resetRounds forgets the rounds of the previous story.
+func (s *EstimationSession) resetRounds() {
+	s.storyRound = 1
+	s.pastRounds = nil
+}
+
+// setDescription changes the story being estimated, which starts counting
+// its rounds again.
+func (s *EstimationSession) setDescription(description string) {
+	if description != s.description {
+		s.resetRounds()
+	}
+	s.description = description
+}
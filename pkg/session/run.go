@@ -0,0 +1,42 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/logging"
+)
+
+var logger = logging.Logger("session")
+
+// TickInterval is how often Run calls Tick.
+const TickInterval = time.Second
+
+// Run handles the messages received in the room and calls Tick, for
+// frontends that don't do it themselves. It returns when the context
+// is done or the messages channel is closed. Failing to publish, like
+// when too many messages wait for the room to reconnect, is only logged:
+// the room must still be followed.
+func (s *EstimationSession) Run(ctx context.Context, messages <-chan *chatroom.ChatMessage) error {
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			if err := s.Handle(msg); err != nil {
+				logger.Warnw("can't reply to a message", "type", msg.MessageType, "error", err)
+			}
+		case <-ticker.C:
+			if err := s.Tick(); err != nil {
+				logger.Warnw("can't publish to the room", "error", err)
+			}
+		}
+	}
+}
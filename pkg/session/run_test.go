@@ -0,0 +1,46 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+)
+
+func TestRunKeepsGoing(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob")
+	alice, bob := r.peers[0], r.peers[1]
+
+	// bob can't publish, starting with the next heartbeat
+	bob.session.mu.Lock()
+	bob.err = chatroom.ErrOutboxFull
+	bob.session.heartbeatSent = time.Time{}
+	bob.session.mu.Unlock()
+
+	messages := make(chan *chatroom.ChatMessage)
+	done := make(chan error, 1)
+	go func() {
+		done <- bob.session.Run(context.Background(), messages)
+	}()
+	time.Sleep(TickInterval + 100*time.Millisecond)
+
+	if err := alice.session.SetDescription("PROJ-1"); err != nil {
+		t.Fatal(err)
+	}
+	m := alice.outbox[len(alice.outbox)-1]
+	m.From = alice.id
+	select {
+	case messages <- m:
+	case err := <-done:
+		t.Fatalf("Run() = %v after failing to publish", err)
+	}
+	close(messages)
+	if err := <-done; err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	if d := bob.state().Description; d != "PROJ-1" {
+		t.Errorf("description = %q, want %q", d, "PROJ-1")
+	}
+}
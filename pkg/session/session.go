@@ -0,0 +1,293 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/backlog"
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/commitment"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/presence"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Publisher sends messages to the other peers in the room. It is
+// implemented by chatroom.ChatRoom.
+type Publisher interface {
+	Publish(messageType chatroom.ChatMessageType, round int, payload interface{}) error
+}
+
+// EstimationSession holds the state of an estimation room: who is in it,
+// what is being estimated and the votes of each participant. It is updated
+// by the commands of the local user and by the messages received from the
+// other peers, and it is safe for concurrent use.
+type EstimationSession struct {
+	mu sync.Mutex
+
+	pub      Publisher
+	presence Presence
+	self     peer.ID
+
+	// when we last told the room we are still there
+	heartbeatSent time.Time
+
+	participants map[peer.ID]*participant
+	description  string
+	revealed     bool
+	round        int
+	deck         deck.Deck
+	backlog      []backlog.Story
+	current      int
+
+	// rounds of votes of the story being estimated, the current one is
+	// storyRound and the ones before are kept in pastRounds
+	storyRound int
+	pastRounds []StoryRound
+
+	// opening of our vote in the current round, sent on reveal
+	opening     *commitment.Opening
+	openingSent bool
+
+	// facilitator of the room, since when we know it, and the requests sent
+	// to us while we are
+	facilitator      peer.ID
+	facilitatorTerm  int
+	facilitatorSince time.Time
+	claimDeadline    time.Time
+	requests         []Request
+
+	// completed rounds are sent to the recorder once the session is unlocked
+	revealedAt time.Time
+	recorded   bool
+	recorder   func(RoundResult)
+	completed  []RoundResult
+
+	// state synchronization with the peers already in the room
+	syncRequested bool
+	syncDeadline  time.Time
+	syncedFrom    peer.ID
+
+	// reconnections of the room we already rejoined after
+	reconnections int
+}
+
+// Participant is a snapshot of a participant in the room.
+type Participant struct {
+	ID   peer.ID
+	Nick string
+	Self bool
+
+	// Voted is set when the participant committed to a vote.
+	Voted bool
+	// Vote is only known after the reveal, except for ourselves.
+	Vote string
+	// Mismatch is set when the revealed vote doesn't match the commitment.
+	Mismatch bool
+	// Facilitator is set for the participant that controls the room.
+	Facilitator bool
+	// Outlier is set after the reveal when the vote is far from the others.
+	Outlier bool
+	// Away is set when the participant is silent for a while.
+	Away     bool
+	LastSeen time.Time
+}
+
+// State is a snapshot of the estimation session.
+type State struct {
+	Description string
+	Revealed    bool
+	Round       int
+	Deck        deck.Deck
+
+	// Backlog has the stories to estimate, the one being estimated is at
+	// the Current index.
+	Backlog []backlog.Story
+	Current int
+
+	// StoryRound is the number of the round of votes of the current story,
+	// PastRounds has the rounds before it.
+	StoryRound int
+	PastRounds []StoryRound
+	// Statistics of the votes, set after the reveal if any vote has a value.
+	Statistics *Statistics
+
+	// Facilitator controls the room. Requests are the actions other
+	// participants asked us, when we are the facilitator.
+	Facilitator peer.ID
+	Requests    []Request
+
+	// Participants has ourselves first, the others are sorted by nick.
+	Participants []Participant
+}
+
+// New creates the session for the local peer. The presence tells which
+// participants are still in the room, and the deck is used until the room
+// agrees on another one.
+func New(pub Publisher, tracker Presence, self peer.ID, nick string, d deck.Deck) *EstimationSession {
+	return &EstimationSession{
+		pub:           pub,
+		presence:      tracker,
+		self:          self,
+		deck:          d,
+		storyRound:    1,
+		claimDeadline: time.Now().Add(SyncWindow),
+		participants: map[peer.ID]*participant{
+			self: {id: self, nick: nick, addedAt: time.Now()},
+		},
+	}
+}
+
+// State returns a snapshot of the session.
+func (s *EstimationSession) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var st *Statistics
+	if stats, ok := s.statistics(); ok && s.revealed {
+		st = &stats
+	}
+
+	return State{
+		Description:  s.description,
+		Revealed:     s.revealed,
+		Round:        s.round,
+		Deck:         s.deck,
+		Backlog:      append([]backlog.Story(nil), s.backlog...),
+		Current:      s.current,
+		StoryRound:   s.storyRound,
+		PastRounds:   append([]StoryRound(nil), s.pastRounds...),
+		Statistics:   st,
+		Facilitator:  s.facilitator,
+		Requests:     append([]Request(nil), s.requests...),
+		Participants: s.participantList(),
+	}
+}
+
+// participantList returns the participants with ourselves first and the
+// others sorted by nick.
+func (s *EstimationSession) participantList() []Participant {
+	outliers := map[peer.ID]bool{}
+	if st, ok := s.statistics(); ok && s.revealed {
+		for _, id := range st.Outliers {
+			outliers[id] = true
+		}
+	}
+
+	participants := make([]Participant, 0, len(s.participants))
+	for _, p := range s.participants {
+		participant := Participant{
+			ID:          p.id,
+			Nick:        p.nick,
+			Self:        p.id == s.self,
+			Voted:       p.commitment != "",
+			Vote:        p.vote,
+			Mismatch:    p.mismatch,
+			Facilitator: p.id == s.facilitator,
+			Outlier:     outliers[p.id],
+		}
+		if pp, ok := s.presence.Peer(p.id); ok && p.id != s.self {
+			participant.Away = pp.Status == presence.Away
+			participant.LastSeen = pp.LastSeen
+		}
+		participants = append(participants, participant)
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		if participants[i].Self != participants[j].Self {
+			return participants[i].Self
+		}
+		return participants[i].Nick < participants[j].Nick
+	})
+	return participants
+}
+
+// SetDescription changes what is being estimated.
+func (s *EstimationSession) SetDescription(description string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isFacilitator() {
+		return s.request(ActionSetDescription)
+	}
+	s.setDescription(description)
+	return s.pub.Publish(chatroom.SetDescription, s.round, chatroom.DescriptionPayload{Description: description})
+}
+
+// Handle updates the session with a message received from another peer.
+func (s *EstimationSession) Handle(msg *chatroom.ChatMessage) error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	// the first peer we see can tell us what happened before we joined,
+	// unless we were the ones there before
+	if _, ok := s.participants[msg.From]; !ok && !s.hasState() {
+		if err := s.requestState(); err != nil {
+			return err
+		}
+	}
+
+	// control messages are only obeyed when sent by the facilitator
+	if controlMessages[msg.MessageType] && !s.fromFacilitator(msg.From) {
+		return nil
+	}
+
+	switch msg.MessageType {
+	case chatroom.Heartbeat:
+		s.updateParticipant(msg.From, msg.SenderNick)
+	case chatroom.SetDescription:
+		var p chatroom.DescriptionPayload
+		if err := msg.Decode(&p); err == nil {
+			s.setDescription(p.Description)
+		}
+	case chatroom.SendVote:
+		var p chatroom.VotePayload
+		if err := msg.Decode(&p); err != nil || p.Commitment == "" {
+			return nil
+		}
+		s.updateParticipant(msg.From, msg.SenderNick)
+		return s.commitVote(msg.From, p.Commitment, "")
+	case chatroom.RevealVote:
+		var p chatroom.OpeningPayload
+		if err := msg.Decode(&p); err == nil {
+			s.updateParticipant(msg.From, msg.SenderNick)
+			s.openVote(msg.From, p.Opening)
+		}
+	case chatroom.ClearVotes:
+		s.clearRound(msg)
+	case chatroom.SetDeck:
+		s.changeDeck(msg)
+	case chatroom.SetBacklog:
+		s.changeBacklog(msg)
+	case chatroom.MoveStory:
+		s.changeStory(msg)
+	case chatroom.ShowVotes:
+		return s.reveal()
+	case chatroom.RequestState:
+		return s.sendState(msg.From)
+	case chatroom.SendState:
+		s.applyState(msg.From, msg)
+	case chatroom.SetFacilitator:
+		return s.changeFacilitator(msg.From, msg)
+	case chatroom.RequestControl:
+		var p chatroom.RequestControlPayload
+		if err := msg.Decode(&p); err == nil {
+			s.addRequest(msg.From, p.Action)
+		}
+	case chatroom.Revote:
+		s.startRevote(msg)
+	}
+	return nil
+}
+
+// controlMessages can only be sent by the facilitator.
+var controlMessages = map[chatroom.ChatMessageType]bool{
+	chatroom.SetDescription: true,
+	chatroom.ClearVotes:     true,
+	chatroom.ShowVotes:      true,
+	chatroom.SetDeck:        true,
+	chatroom.SetBacklog:     true,
+	chatroom.MoveStory:      true,
+	chatroom.Revote:         true,
+}
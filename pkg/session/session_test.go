@@ -0,0 +1,840 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/backlog"
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/commitment"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/presence"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// testPeer is a peer of a testRoom. It publishes by queueing the messages,
+// which the room delivers to the other peers.
+type testPeer struct {
+	id       peer.ID
+	nick     string
+	session  *EstimationSession
+	presence *presence.Tracker
+	silent   map[peer.ID]bool
+	outbox   []*chatroom.ChatMessage
+	// err, if set, is returned instead of publishing
+	err error
+}
+
+// Peer tells the session what the tracker knows about the peer, as if we
+// didn't hear from the silenced peers for presence.LeaveAfter.
+func (p *testPeer) Peer(id peer.ID) (presence.Peer, bool) {
+	pp, ok := p.presence.Peer(id)
+	if ok && p.silent[id] {
+		pp.LastSeen = time.Now().Add(-presence.LeaveAfter - time.Second)
+		pp.Status = presence.Left
+	}
+	return pp, ok
+}
+
+func (p *testPeer) silence(id peer.ID) {
+	p.session.mu.Lock()
+	defer p.session.mu.Unlock()
+	p.silent[id] = true
+}
+
+func (p *testPeer) Publish(messageType chatroom.ChatMessageType, round int, payload interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	m := &chatroom.ChatMessage{
+		Version:     chatroom.Version,
+		MessageType: messageType,
+		SenderID:    p.id.Pretty(),
+		SenderNick:  p.nick,
+		SentAt:      time.Now().UTC(),
+		Round:       round,
+		From:        p.id,
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		m.Payload = data
+	}
+	p.outbox = append(p.outbox, m)
+	return nil
+}
+
+func (p *testPeer) state() State {
+	return p.session.State()
+}
+
+// testRoom connects sessions without the network.
+type testRoom struct {
+	t     *testing.T
+	peers []*testPeer
+}
+
+func newTestID(t *testing.T) peer.ID {
+	t.Helper()
+
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// newTestRoom creates a room where everyone knows each other and the
+// first peer is the facilitator.
+func newTestRoom(t *testing.T, nicks ...string) *testRoom {
+	t.Helper()
+
+	r := &testRoom{t: t}
+	for _, nick := range nicks {
+		r.join(nick)
+	}
+	for _, p := range r.peers {
+		if err := p.session.Tick(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.deliver()
+
+	first := r.peers[0].session
+	first.mu.Lock()
+	first.claimDeadline = time.Now()
+	first.mu.Unlock()
+	if err := first.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	r.deliver()
+	return r
+}
+
+func (r *testRoom) join(nick string) *testPeer {
+	p := &testPeer{
+		id:       newTestID(r.t),
+		nick:     nick,
+		presence: presence.NewTracker(),
+		silent:   map[peer.ID]bool{},
+	}
+	p.session = New(p, p, p.id, nick, deck.Default)
+	r.peers = append(r.peers, p)
+	return p
+}
+
+// deliver sends the messages published to the other peers, until nobody
+// has anything else to say.
+func (r *testRoom) deliver() {
+	r.t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		var from *testPeer
+		for _, p := range r.peers {
+			if len(p.outbox) > 0 {
+				from = p
+				break
+			}
+		}
+		if from == nil {
+			return
+		}
+		m := from.outbox[0]
+		from.outbox = from.outbox[1:]
+		r.send(from, m)
+	}
+	r.t.Fatal("the peers keep talking")
+}
+
+// send delivers a message as if it was published by from.
+func (r *testRoom) send(from *testPeer, m *chatroom.ChatMessage) {
+	r.t.Helper()
+
+	for _, p := range r.peers {
+		if p == from {
+			continue
+		}
+		p.presence.Seen(m.From, m.SenderNick)
+		if err := p.session.Handle(m); err != nil {
+			r.t.Fatalf("%s handling %s: %s", p.nick, m.MessageType, err)
+		}
+	}
+}
+
+// findParticipant finds a participant in the state by nick.
+func findParticipant(t *testing.T, st State, nick string) Participant {
+	t.Helper()
+
+	for _, p := range st.Participants {
+		if p.Nick == nick {
+			return p
+		}
+	}
+	t.Fatalf("%s isn't in the room", nick)
+	return Participant{}
+}
+
+func TestVotes(t *testing.T) {
+	tests := []struct {
+		name   string
+		votes  map[string]string
+		reveal bool
+		// want has the votes seen by bob, "" when it isn't known yet
+		want     map[string]string
+		revealed bool
+	}{
+		{
+			name:  "votes are hidden until the reveal",
+			votes: map[string]string{"alice": "3"},
+			want:  map[string]string{"alice": "", "carol": ""},
+		},
+		{
+			name:     "votes are revealed once everyone voted",
+			votes:    map[string]string{"alice": "3", "bob": "5", "carol": "8"},
+			want:     map[string]string{"alice": "3", "bob": "5", "carol": "8"},
+			revealed: true,
+		},
+		{
+			name:     "the facilitator reveals before everyone voted",
+			votes:    map[string]string{"alice": "3", "bob": "5"},
+			reveal:   true,
+			want:     map[string]string{"alice": "3", "bob": "5", "carol": ""},
+			revealed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(t, "alice", "bob", "carol")
+			for _, p := range r.peers {
+				if vote, ok := tt.votes[p.nick]; ok {
+					if err := p.session.Vote(vote); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			r.deliver()
+			if tt.reveal {
+				if err := r.peers[0].session.Reveal(); err != nil {
+					t.Fatal(err)
+				}
+				r.deliver()
+			}
+
+			st := r.peers[1].state()
+			if st.Revealed != tt.revealed {
+				t.Errorf("revealed = %t, want %t", st.Revealed, tt.revealed)
+			}
+			for nick, want := range tt.want {
+				p := findParticipant(t, st, nick)
+				if p.Vote != want {
+					t.Errorf("vote of %s = %q, want %q", nick, p.Vote, want)
+				}
+				_, voted := tt.votes[nick]
+				if p.Voted != voted {
+					t.Errorf("%s voted = %t, want %t", nick, p.Voted, voted)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenings(t *testing.T) {
+	tests := []struct {
+		name     string
+		opening  func(o commitment.Opening) string
+		early    bool
+		want     string
+		mismatch bool
+	}{
+		{
+			name:    "opening matches the commitment",
+			opening: func(o commitment.Opening) string { return o.Encode() },
+			want:    "5",
+		},
+		{
+			name:    "opening arrives before the commitment",
+			opening: func(o commitment.Opening) string { return o.Encode() },
+			early:   true,
+			want:    "5",
+		},
+		{
+			name: "opening with another vote",
+			opening: func(o commitment.Opening) string {
+				o.Vote = "13"
+				return o.Encode()
+			},
+			mismatch: true,
+		},
+		{
+			name:     "opening that can't be decoded",
+			opening:  func(o commitment.Opening) string { return "garbage" },
+			mismatch: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(t, "alice", "bob", "mallory")
+			alice, mallory := r.peers[0], r.peers[2]
+
+			c, o, err := commitment.Commit("5")
+			if err != nil {
+				t.Fatal(err)
+			}
+			commit := func() {
+				mallory.Publish(chatroom.SendVote, 1, chatroom.VotePayload{Commitment: c})
+				r.deliver()
+			}
+			open := func() {
+				mallory.Publish(chatroom.RevealVote, 1, chatroom.OpeningPayload{Opening: tt.opening(o)})
+				r.deliver()
+			}
+			if tt.early {
+				open()
+				commit()
+			} else {
+				commit()
+				open()
+			}
+
+			p := findParticipant(t, alice.state(), "mallory")
+			if p.Vote != tt.want || p.Mismatch != tt.mismatch {
+				t.Errorf("mallory has vote %q and mismatch %t, want %q and %t", p.Vote, p.Mismatch, tt.want, tt.mismatch)
+			}
+		})
+	}
+}
+
+func TestClear(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob")
+	alice, bob := r.peers[0], r.peers[1]
+	alice.session.Vote("3")
+	bob.session.Vote("5")
+	r.deliver()
+
+	round := bob.state().Round
+	if err := alice.session.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	r.deliver()
+
+	for _, p := range r.peers {
+		st := p.state()
+		if st.Revealed {
+			t.Errorf("%s still sees the votes revealed", p.nick)
+		}
+		if st.Round != round+1 {
+			t.Errorf("%s is in round %d, want %d", p.nick, st.Round, round+1)
+		}
+		for _, pp := range st.Participants {
+			if pp.Voted || pp.Vote != "" {
+				t.Errorf("%s still sees the vote of %s", p.nick, pp.Nick)
+			}
+		}
+	}
+}
+
+func TestRevote(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob")
+	alice, bob := r.peers[0], r.peers[1]
+
+	if err := alice.session.Revote(); !errors.Is(err, ErrNotRevealed) {
+		t.Fatalf("Revote() before the reveal = %v, want ErrNotRevealed", err)
+	}
+
+	alice.session.Vote("1")
+	bob.session.Vote("13")
+	r.deliver()
+	if err := alice.session.Revote(); err != nil {
+		t.Fatal(err)
+	}
+	r.deliver()
+
+	for _, p := range r.peers {
+		st := p.state()
+		if st.StoryRound != 2 {
+			t.Errorf("%s is in story round %d, want 2", p.nick, st.StoryRound)
+		}
+		if len(st.PastRounds) != 1 || len(st.PastRounds[0].Votes) != 2 {
+			t.Fatalf("%s has past rounds %+v, want the first one with 2 votes", p.nick, st.PastRounds)
+		}
+		if st.Revealed {
+			t.Errorf("%s still sees the votes revealed", p.nick)
+		}
+	}
+}
+
+func TestFacilitator(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(s *EstimationSession) error
+		check  func(st State) bool
+	}{
+		{"reveal", (*EstimationSession).Reveal, func(st State) bool { return st.Revealed }},
+		{"clear", (*EstimationSession).Clear, func(st State) bool { return st.Round > 0 }},
+		{
+			"set the description",
+			func(s *EstimationSession) error { return s.SetDescription("login page") },
+			func(st State) bool { return st.Description == "login page" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(t, "alice", "bob")
+			alice, bob := r.peers[0], r.peers[1]
+			if f := bob.state().Facilitator; f != alice.id {
+				t.Fatalf("bob sees %s as the facilitator, want alice", f)
+			}
+
+			// bob's actions become requests to alice
+			if err := tt.action(bob.session); !errors.Is(err, ErrRequested) {
+				t.Fatalf("bob got %v, want ErrRequested", err)
+			}
+			r.deliver()
+			if tt.check(alice.state()) {
+				t.Error("alice obeyed bob")
+			}
+			requests := alice.state().Requests
+			if len(requests) != 1 || requests[0].Nick != "bob" {
+				t.Errorf("alice has requests %+v, want bob's", requests)
+			}
+
+			// alice does it
+			if err := tt.action(alice.session); err != nil {
+				t.Fatal(err)
+			}
+			r.deliver()
+			if !tt.check(bob.state()) {
+				t.Error("bob didn't obey alice")
+			}
+		})
+	}
+}
+
+func TestHandOver(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob")
+	alice, bob := r.peers[0], r.peers[1]
+
+	if err := bob.session.HandOver(bob.id); !errors.Is(err, ErrNotFacilitator) {
+		t.Fatalf("bob handing over = %v, want ErrNotFacilitator", err)
+	}
+	if err := alice.session.HandOver(bob.id); err != nil {
+		t.Fatal(err)
+	}
+	r.deliver()
+
+	for _, p := range r.peers {
+		if f := p.state().Facilitator; f != bob.id {
+			t.Errorf("%s sees %s as the facilitator, want bob", p.nick, f)
+		}
+	}
+	if err := alice.session.Reveal(); !errors.Is(err, ErrRequested) {
+		t.Errorf("alice revealing after the hand over = %v, want ErrRequested", err)
+	}
+}
+
+func TestVotesOfUnknownPeers(t *testing.T) {
+	r := newTestRoom(t, "alice")
+	alice := r.peers[0]
+	bob := r.join("bob")
+
+	// alice hears bob's vote before anything else from him
+	c, _, err := commitment.Commit("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.Publish(chatroom.SendVote, 1, chatroom.VotePayload{Commitment: c})
+	r.send(bob, bob.outbox[0])
+
+	p := findParticipant(t, alice.state(), "bob")
+	if !p.Voted {
+		t.Error("bob's vote was lost")
+	}
+}
+
+func TestLeave(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob", "carol")
+	bob, carol := r.peers[1], r.peers[2]
+	if err := carol.session.Vote("5"); err != nil {
+		t.Fatal(err)
+	}
+	r.deliver()
+
+	// pubsub only tells about the peers bob is connected to, carol can
+	// still be in the room through alice
+	bob.presence.Left(carol.id)
+	if err := bob.session.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	if p := findParticipant(t, bob.state(), "carol"); !p.Voted {
+		t.Error("carol's vote was lost")
+	}
+
+	bob.silence(carol.id)
+	if err := bob.session.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range bob.state().Participants {
+		if p.Nick == "carol" {
+			t.Error("carol is still in the room after being silent")
+		}
+	}
+}
+
+func TestApplyState(t *testing.T) {
+	aliceVote, aliceOpening, _ := commitment.Commit("3")
+	otherVote, otherOpening, _ := commitment.Commit("8")
+	bobVote, _, _ := commitment.Commit("5")
+
+	tests := []struct {
+		name string
+		// seen is the commitment of alice carol saw herself
+		seen  string
+		alice snapshotParticipant
+		want  Participant
+	}{
+		{
+			name:  "commitment without opening",
+			alice: snapshotParticipant{Commitment: aliceVote},
+		},
+		{
+			name:  "commitment and opening that verify",
+			alice: snapshotParticipant{Commitment: aliceVote, Opening: aliceOpening.Encode()},
+			want:  Participant{Voted: true, Vote: "3"},
+		},
+		{
+			name:  "commitment and opening that don't verify",
+			alice: snapshotParticipant{Commitment: aliceVote, Opening: otherOpening.Encode()},
+		},
+		{
+			name:  "opening of a commitment we didn't see",
+			seen:  aliceVote,
+			alice: snapshotParticipant{Commitment: otherVote, Opening: otherOpening.Encode()},
+			want:  Participant{Voted: true},
+		},
+		{
+			name:  "opening of the commitment we saw",
+			seen:  aliceVote,
+			alice: snapshotParticipant{Commitment: otherVote, Opening: aliceOpening.Encode()},
+			want:  Participant{Voted: true, Vote: "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(t, "alice", "bob")
+			alice, bob := r.peers[0], r.peers[1]
+			carol := r.join("carol")
+
+			alice.Publish(chatroom.Heartbeat, 0, nil)
+			r.send(alice, alice.outbox[0])
+			alice.outbox = nil
+			if tt.seen != "" {
+				alice.Publish(chatroom.SendVote, 0, chatroom.VotePayload{Commitment: tt.seen})
+				r.send(alice, alice.outbox[0])
+				alice.outbox = nil
+			}
+			carol.session.mu.Lock()
+			carol.session.requestState()
+			carol.session.mu.Unlock()
+			carol.outbox = nil
+
+			tt.alice.ID = alice.id.Pretty()
+			bob.Publish(chatroom.SendState, 0, snapshot{
+				RequestedBy: carol.id.Pretty(),
+				Participants: []snapshotParticipant{
+					{ID: bob.id.Pretty(), Nick: "bob", Commitment: bobVote},
+					tt.alice,
+				},
+			})
+			r.send(bob, bob.outbox[0])
+
+			st := carol.state()
+			if !findParticipant(t, st, "bob").Voted {
+				t.Error("the vote of bob, who sent the state, was ignored")
+			}
+			p := findParticipant(t, st, "alice")
+			if p.Voted != tt.want.Voted || p.Vote != tt.want.Vote || p.Mismatch {
+				t.Errorf("alice has vote %q, voted %t and mismatch %t, want %q, %t and false", p.Vote, p.Voted, p.Mismatch, tt.want.Vote, tt.want.Voted)
+			}
+		})
+	}
+}
+
+func TestRequestState(t *testing.T) {
+	r := newTestRoom(t, "alice")
+	alice := r.peers[0]
+	bob := r.join("bob")
+
+	bob.session.Tick()
+	r.send(bob, bob.outbox[0])
+	bob.outbox = nil
+	for _, m := range alice.outbox {
+		if m.MessageType == chatroom.RequestState {
+			t.Error("alice, who was in the room first, asked bob for its state")
+		}
+	}
+
+	// bob, who knows nothing, asks alice
+	alice.Publish(chatroom.Heartbeat, 0, nil)
+	r.deliver()
+	if f := bob.state().Facilitator; f != alice.id {
+		t.Errorf("bob sees %s as the facilitator, want alice", f)
+	}
+}
+
+func TestOnRoundCompleted(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob")
+	alice, bob := r.peers[0], r.peers[1]
+
+	var results []RoundResult
+	for _, p := range r.peers {
+		s := p.session
+		s.OnRoundCompleted(func(result RoundResult) {
+			// the session is unlocked, so the recorder can use it
+			if st := s.State(); st.Revealed {
+				t.Error("the round completed is still revealed")
+			}
+			results = append(results, result)
+		})
+	}
+
+	alice.session.SetDescription("login page")
+	alice.session.Vote("3")
+	bob.session.Vote("5")
+	r.deliver()
+	if err := alice.session.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	r.deliver()
+
+	if len(results) != 2 {
+		t.Fatalf("%d rounds recorded, want one for each peer", len(results))
+	}
+	for _, result := range results {
+		if result.Story != "login page" || result.Round != 1 || len(result.Votes) != 2 || result.Estimate != "3" {
+			t.Errorf("recorded %+v", result)
+		}
+	}
+}
+
+func TestFacilitatorClaims(t *testing.T) {
+	tests := []struct {
+		name  string
+		claim func(alice, bob, mallory *testPeer) facilitatorMessage
+		// left is set when alice left the room before the claim
+		left bool
+		want func(alice, bob, mallory *testPeer) peer.ID
+	}{
+		{
+			name: "claim with a higher term",
+			claim: func(alice, bob, mallory *testPeer) facilitatorMessage {
+				return facilitatorMessage{Facilitator: mallory.id.Pretty(), Term: 1, Since: time.Now()}
+			},
+			want: func(alice, bob, mallory *testPeer) peer.ID { return alice.id },
+		},
+		{
+			name: "back-dated claim",
+			claim: func(alice, bob, mallory *testPeer) facilitatorMessage {
+				return facilitatorMessage{Facilitator: mallory.id.Pretty(), Since: time.Now().Add(-time.Hour)}
+			},
+			want: func(alice, bob, mallory *testPeer) peer.ID { return alice.id },
+		},
+		{
+			name: "hand over by someone else",
+			claim: func(alice, bob, mallory *testPeer) facilitatorMessage {
+				return facilitatorMessage{Facilitator: bob.id.Pretty(), Term: 1, Since: time.Now()}
+			},
+			want: func(alice, bob, mallory *testPeer) peer.ID { return alice.id },
+		},
+		{
+			name: "claim after the facilitator left",
+			claim: func(alice, bob, mallory *testPeer) facilitatorMessage {
+				return facilitatorMessage{Facilitator: mallory.id.Pretty(), Term: 1, Since: time.Now()}
+			},
+			left: true,
+			want: func(alice, bob, mallory *testPeer) peer.ID { return mallory.id },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(t, "alice", "bob", "mallory")
+			alice, bob, mallory := r.peers[0], r.peers[1], r.peers[2]
+			// claims are only contested right after the room starts
+			for _, p := range r.peers {
+				p.session.mu.Lock()
+				p.session.facilitatorSince = time.Now().Add(-SyncWindow)
+				p.session.mu.Unlock()
+			}
+			if tt.left {
+				bob.silence(alice.id)
+				r.peers = r.peers[1:]
+			}
+
+			mallory.Publish(chatroom.SetFacilitator, 0, tt.claim(alice, bob, mallory))
+			r.deliver()
+
+			if f, want := bob.state().Facilitator, tt.want(alice, bob, mallory); f != want {
+				t.Errorf("bob sees %s as the facilitator, want %s", f, want)
+			}
+		})
+	}
+}
+
+func TestConcurrentClaims(t *testing.T) {
+	r := &testRoom{t: t}
+	for _, nick := range []string{"alice", "bob", "carol"} {
+		r.join(nick)
+	}
+	lowest := r.peers[0].id
+	for _, p := range r.peers {
+		if p.id < lowest {
+			lowest = p.id
+		}
+		p.session.Tick()
+	}
+	r.deliver()
+
+	// everyone claims the role before hearing the others
+	for _, p := range r.peers {
+		p.session.mu.Lock()
+		p.session.claimDeadline = time.Now()
+		p.session.mu.Unlock()
+		p.session.Tick()
+	}
+	r.deliver()
+
+	for _, p := range r.peers {
+		if f := p.state().Facilitator; f != lowest {
+			t.Errorf("%s sees %s as the facilitator, want %s", p.nick, f, lowest)
+		}
+	}
+}
+
+func TestClearNumbersTheRounds(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob")
+	alice, bob := r.peers[0], r.peers[1]
+
+	recorded := map[string][]int{}
+	for _, p := range r.peers {
+		p := p
+		p.session.OnRoundCompleted(func(result RoundResult) {
+			recorded[p.nick] = append(recorded[p.nick], result.Round)
+		})
+	}
+
+	// clearing before the reveal doesn't start a round of the story
+	alice.session.Vote("3")
+	r.deliver()
+	alice.session.Clear()
+	r.deliver()
+
+	for i := 0; i < 2; i++ {
+		alice.session.Vote("3")
+		bob.session.Vote("5")
+		r.deliver()
+		if err := alice.session.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		r.deliver()
+	}
+
+	for _, p := range r.peers {
+		if got := recorded[p.nick]; !reflect.DeepEqual(got, []int{1, 2}) {
+			t.Errorf("%s recorded rounds %v, want [1 2]", p.nick, got)
+		}
+		if sr := p.state().StoryRound; sr != 3 {
+			t.Errorf("%s is in story round %d, want 3", p.nick, sr)
+		}
+	}
+}
+
+func TestClearKeepsPastRounds(t *testing.T) {
+	r := newTestRoom(t, "alice", "bob")
+	alice, bob := r.peers[0], r.peers[1]
+
+	// revealed and cleared, then revealed and voted again
+	for _, next := range []func() error{alice.session.Clear, alice.session.Revote} {
+		alice.session.Vote("3")
+		bob.session.Vote("5")
+		r.deliver()
+		if err := next(); err != nil {
+			t.Fatal(err)
+		}
+		r.deliver()
+	}
+
+	for _, p := range r.peers {
+		st := p.state()
+		var numbers []int
+		for _, sr := range st.PastRounds {
+			numbers = append(numbers, sr.Number)
+		}
+		if !reflect.DeepEqual(numbers, []int{1, 2}) {
+			t.Errorf("%s has past rounds %v, want [1 2]", p.nick, numbers)
+		}
+		if st.StoryRound != 3 {
+			t.Errorf("%s is in story round %d, want 3", p.nick, st.StoryRound)
+		}
+	}
+}
+
+func TestFailedActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(s *EstimationSession) error
+	}{
+		{"clear", (*EstimationSession).Clear},
+		{"revote", (*EstimationSession).Revote},
+		{"set deck", func(s *EstimationSession) error {
+			return s.SetDeck(deck.Deck{Name: "t-shirt", Cards: []string{"S", "M", "L"}})
+		}},
+		{"next story", (*EstimationSession).NextStory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(t, "alice", "bob")
+			alice := r.peers[0]
+			stories := []backlog.Story{{Title: "login page"}, {Title: "logout"}}
+			if err := alice.session.LoadBacklog(stories); err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range r.peers {
+				if err := p.session.Vote("3"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r.deliver()
+			want := alice.state()
+
+			alice.err = chatroom.ErrOutboxFull
+			for i := 0; i < 3; i++ {
+				if err := tt.action(alice.session); !errors.Is(err, chatroom.ErrOutboxFull) {
+					t.Fatalf("%s = %v, want ErrOutboxFull", tt.name, err)
+				}
+			}
+			if got := alice.state(); !reflect.DeepEqual(got, want) {
+				t.Errorf("state changed by failed attempts:\n%+v\nwant\n%+v", got, want)
+			}
+
+			alice.err = nil
+			if err := tt.action(alice.session); err != nil {
+				t.Fatal(err)
+			}
+			r.deliver()
+			if a, b := alice.state().Round, r.peers[1].state().Round; a != want.Round+1 || b != a {
+				t.Errorf("rounds = %d and %d, want %d", a, b, want.Round+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/renato0307/p2p-estimator/pkg/stats"
+)
+
+// Statistics of the votes revealed. Values are in the units of the deck,
+// or card positions for decks that aren't numeric; use the deck to format
+// them.
+type Statistics struct {
+	stats.Summary
+	// Nearest is the card nearest to the average.
+	Nearest string
+	Verdict stats.Verdict
+	// Outliers are the participants whose votes are far from the others,
+	// who should explain their estimate.
+	Outliers []peer.ID
+}
+
+// Statistics returns the statistics of the votes revealed. It returns
+// false when the votes aren't revealed or nobody played a card with a
+// value.
+func (s *EstimationSession) Statistics() (Statistics, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.revealed {
+		return Statistics{}, false
+	}
+	return s.statistics()
+}
+
+func (s *EstimationSession) statistics() (Statistics, bool) {
+	var ids []peer.ID
+	var values []float64
+	var positions []int
+	for id, p := range s.participants {
+		val, ok := s.deck.Value(p.vote)
+		if !ok {
+			continue
+		}
+		pos, _ := s.deck.Position(p.vote)
+		ids = append(ids, id)
+		values = append(values, val)
+		positions = append(positions, pos)
+	}
+
+	summary, ok := stats.Summarize(values)
+	if !ok {
+		return Statistics{}, false
+	}
+
+	st := Statistics{
+		Summary: summary,
+		Nearest: s.deck.Nearest(summary.Mean),
+		Verdict: stats.Agreement(positions),
+	}
+	for _, i := range stats.Outliers(positions) {
+		st.Outliers = append(st.Outliers, ids[i])
+	}
+	return st, true
+}
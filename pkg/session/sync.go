@@ -0,0 +1,265 @@
+package session
+
+import (
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/backlog"
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/commitment"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// SyncWindow is for how long after requesting the state of the room we
+// accept the snapshots sent by the other peers.
+const SyncWindow = 5 * time.Second
+
+// snapshot is the state of the room sent to peers that just joined.
+type snapshot struct {
+	RequestedBy  string
+	Round        int
+	Description  string
+	Revealed     bool
+	Deck         deck.Deck
+	Backlog      []backlog.Story
+	Current      int
+	StoryRound   int
+	PastRounds   []StoryRound
+	Facilitator  *facilitatorMessage
+	Participants []snapshotParticipant
+}
+
+type snapshotParticipant struct {
+	ID         string
+	Nick       string
+	Commitment string
+	// Opening is only sent after the reveal, so the receiver can check it
+	// against the commitment.
+	Opening string
+}
+
+// rank orders snapshots to resolve conflicts deterministically: the most
+// recent round wins, then the one with more votes, then the revealed one
+// and then the one with a description.
+func (s *snapshot) rank() [4]int {
+	votes := 0
+	for _, p := range s.Participants {
+		if p.Commitment != "" {
+			votes++
+		}
+	}
+	return [4]int{s.Round, votes, boolToInt(s.Revealed), boolToInt(s.Description != "")}
+}
+
+// outranks tells if the snapshot, sent by from, wins over the other one.
+// On a tie the snapshot sent by the lowest peer ID wins.
+func (s *snapshot) outranks(from peer.ID, other *snapshot, otherFrom peer.ID) bool {
+	r, o := s.rank(), other.rank()
+	for i := range r {
+		if r[i] != o[i] {
+			return r[i] > o[i]
+		}
+	}
+	return from < otherFrom
+}
+
+// requestState asks the other peers for the state of the room. It is sent
+// once, when we see the first peer while we don't know anything about the
+// room, or after reconnecting.
+func (s *EstimationSession) requestState() error {
+	if s.syncRequested {
+		return nil
+	}
+	s.syncRequested = true
+	s.syncDeadline = time.Now().Add(SyncWindow)
+	return s.pub.Publish(chatroom.RequestState, s.round, nil)
+}
+
+// sendState answers a state request from a peer.
+func (s *EstimationSession) sendState(requestedBy peer.ID) error {
+	snap := s.snapshot()
+	snap.RequestedBy = requestedBy.Pretty()
+
+	return s.pub.Publish(chatroom.SendState, s.round, snap)
+}
+
+// applyState replaces our state with the snapshot sent by a peer, if we
+// asked for it and it wins over the state we have.
+func (s *EstimationSession) applyState(from peer.ID, msg *chatroom.ChatMessage) {
+	if !s.syncRequested || time.Now().After(s.syncDeadline) {
+		return
+	}
+
+	snap := new(snapshot)
+	if err := msg.Decode(snap); err != nil {
+		return
+	}
+	if snap.RequestedBy != s.self.Pretty() {
+		return
+	}
+
+	// the facilitator is taken from any snapshot while we don't know it,
+	// even if we keep our state, and otherwise as if the sender announced it
+	if snap.Facilitator != nil && s.snapshotFacilitator(from, snap.Facilitator) {
+		s.setFacilitator(snap.Facilitator)
+	}
+
+	current, currentFrom := s.snapshot(), s.self
+	if s.syncedFrom != "" {
+		currentFrom = s.syncedFrom
+	}
+	if !snap.outranks(from, current, currentFrom) {
+		return
+	}
+	s.syncedFrom = from
+
+	if snap.Round != s.round {
+		s.clearVotes()
+		s.round = snap.Round
+	}
+	s.description = snap.Description
+	if snap.StoryRound > 0 {
+		s.storyRound = snap.StoryRound
+		s.pastRounds = snap.PastRounds
+		for _, sr := range s.pastRounds {
+			for i := range sr.Votes {
+				sr.Votes[i].Self = sr.Votes[i].ID == s.self
+			}
+		}
+	}
+	if len(snap.Deck.Cards) > 0 {
+		s.deck = snap.Deck
+	}
+	if snap.Current >= 0 && snap.Current < len(snap.Backlog) {
+		s.backlog = snap.Backlog
+		s.current = snap.Current
+	}
+
+	for _, sp := range snap.Participants {
+		id, err := peer.Decode(sp.ID)
+		// we can't reveal our own vote without the opening, so we ignore it
+		if err != nil || id == s.self {
+			continue
+		}
+		if id == from {
+			s.applyOwnEntry(id, sp)
+		} else {
+			s.applyEntry(id, sp)
+		}
+	}
+
+	if snap.Revealed {
+		s.revealed = true
+	}
+}
+
+func (s *EstimationSession) snapshotFacilitator(from peer.ID, fm *facilitatorMessage) bool {
+	id, err := peer.Decode(fm.Facilitator)
+	if err != nil {
+		return false
+	}
+	return s.facilitator == "" || s.acceptFacilitator(from, id, fm.Term)
+}
+
+// applyOwnEntry takes the entry of the peer that sent the snapshot, which
+// speaks for itself.
+func (s *EstimationSession) applyOwnEntry(id peer.ID, sp snapshotParticipant) {
+	p := s.updateParticipant(id, sp.Nick)
+	if sp.Commitment != "" {
+		p.commitment = sp.Commitment
+	}
+	if sp.Opening != "" {
+		s.openVote(id, sp.Opening)
+	}
+}
+
+// applyEntry takes the vote of another participant from a snapshot only if
+// its commitment and opening verify against each other, since the sender
+// could make up anything else. An opening that doesn't match the
+// commitment we saw ourselves is kept pending instead of flagging the
+// participant, so a snapshot can't frame them.
+func (s *EstimationSession) applyEntry(id peer.ID, sp snapshotParticipant) {
+	if sp.Opening == "" {
+		return
+	}
+	if p, ok := s.participants[id]; ok && p.commitment != "" {
+		if verifies(p.commitment, sp.Opening) {
+			s.openVote(id, sp.Opening)
+		} else {
+			p.pendingOpening = sp.Opening
+		}
+		return
+	}
+	if !verifies(sp.Commitment, sp.Opening) {
+		return
+	}
+	p := s.updateParticipant(id, sp.Nick)
+	p.commitment = sp.Commitment
+	s.openVote(id, sp.Opening)
+}
+
+// hasState tells if we know anything about the room worth keeping, in
+// which case we don't need to ask for its state.
+func (s *EstimationSession) hasState() bool {
+	if s.round > 0 || s.description != "" || len(s.backlog) > 0 || s.facilitator != "" {
+		return true
+	}
+	for _, p := range s.participants {
+		if p.commitment != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func verifies(c string, encoded string) bool {
+	o, err := commitment.Decode(encoded)
+	return err == nil && commitment.Verify(c, o)
+}
+
+func (s *EstimationSession) snapshot() *snapshot {
+	snap := &snapshot{
+		Round:       s.round,
+		Description: s.description,
+		Revealed:    s.revealed,
+		Deck:        s.deck,
+		Backlog:     s.backlog,
+		Current:     s.current,
+		StoryRound:  s.storyRound,
+		PastRounds:  s.pastRounds,
+	}
+	if s.facilitator != "" {
+		snap.Facilitator = s.currentFacilitator()
+	}
+	for _, p := range s.participants {
+		sp := snapshotParticipant{
+			ID:         p.id.Pretty(),
+			Nick:       p.nick,
+			Commitment: p.commitment,
+		}
+		if s.revealed {
+			sp.Opening = p.opening
+			if p.id == s.self && s.opening != nil {
+				sp.Opening = s.opening.Encode()
+			}
+		}
+		snap.Participants = append(snap.Participants, sp)
+	}
+	return snap
+}
+
+// nextRound returns the round to start when a peer announces a new one.
+func (s *EstimationSession) nextRound(round int) int {
+	if round <= s.round {
+		return s.round + 1
+	}
+	return round
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
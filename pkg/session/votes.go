@@ -0,0 +1,220 @@
+package session
+
+import (
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/commitment"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Vote commits to a vote and publishes the commitment. The vote itself is
+// only sent when the round is revealed.
+func (s *EstimationSession) Vote(vote string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, o, err := commitment.Commit(vote)
+	if err != nil {
+		return err
+	}
+	err = s.pub.Publish(chatroom.SendVote, s.round, chatroom.VotePayload{Commitment: c})
+	if err != nil {
+		return err
+	}
+	s.opening = &o
+	s.openingSent = false
+
+	if err := s.commitVote(s.self, c, vote); err != nil {
+		return err
+	}
+	if s.revealed {
+		return s.sendOpening()
+	}
+	return nil
+}
+
+// Reveal shows the votes of the current round to everyone.
+func (s *EstimationSession) Reveal() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isFacilitator() {
+		return s.request(ActionReveal)
+	}
+	if err := s.reveal(); err != nil {
+		return err
+	}
+	return s.pub.Publish(chatroom.ShowVotes, s.round, nil)
+}
+
+// clearMessage is sent when the votes are cleared. It has the round of the
+// story that starts, so peers that missed the reveal number it the same.
+type clearMessage struct {
+	StoryRound int
+}
+
+// Clear removes all the votes and starts a new round. Clearing votes that
+// were revealed keeps them as a past round of the story and starts a new
+// one, so the rounds recorded for it have different numbers. Like the other actions of the
+// facilitator, nothing changes until the room is told, so that it can be
+// tried again.
+func (s *EstimationSession) Clear() error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	if !s.isFacilitator() {
+		return s.request(ActionClear)
+	}
+	cm := clearMessage{StoryRound: s.storyRound}
+	if s.revealed {
+		cm.StoryRound++
+	}
+	if err := s.pub.Publish(chatroom.ClearVotes, s.round+1, cm); err != nil {
+		return err
+	}
+	if s.revealed {
+		s.archiveRound()
+	}
+	s.clearVotes()
+	s.storyRound = cm.StoryRound
+	s.round++
+	return nil
+}
+
+func (s *EstimationSession) clearRound(msg *chatroom.ChatMessage) {
+	var cm clearMessage
+	if err := msg.Decode(&cm); err != nil || cm.StoryRound == 0 {
+		// sent by an older peer
+		cm.StoryRound = s.storyRound
+		if s.revealed {
+			cm.StoryRound++
+		}
+	}
+	if s.revealed {
+		s.archiveRound()
+	}
+	s.clearVotes()
+	s.storyRound = cm.StoryRound
+	s.round = s.nextRound(msg.Round)
+}
+
+// Estimate returns the card agreed by the room, the one nearest to the
+// average of the votes revealed.
+func (s *EstimationSession) Estimate() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.revealed {
+		return "", false
+	}
+	return s.estimate()
+}
+
+func (s *EstimationSession) estimate() (string, bool) {
+	avg, ok := s.average()
+	if !ok {
+		return "", false
+	}
+	return s.deck.Nearest(avg), true
+}
+
+func (s *EstimationSession) average() (float64, bool) {
+	var validVotes float64
+	var sum float64
+	for _, p := range s.participants {
+		val, ok := s.deck.Value(p.vote)
+		if !ok {
+			continue
+		}
+		validVotes++
+		sum += val
+	}
+	if validVotes == 0 {
+		return 0, false
+	}
+	return sum / validVotes, true
+}
+
+// commitVote stores the commitment of a participant. The vote is only known
+// for ourselves, for the other participants it is set when they reveal it.
+// Votes are revealed automatically once everyone voted.
+func (s *EstimationSession) commitVote(id peer.ID, c string, vote string) error {
+	p, ok := s.participants[id]
+	if !ok {
+		return nil
+	}
+	p.commitment = c
+	p.vote = vote
+	p.mismatch = false
+	if p.pendingOpening != "" {
+		s.openVote(id, p.pendingOpening)
+	}
+
+	for _, p := range s.participants {
+		if p.commitment == "" {
+			return nil
+		}
+	}
+	return s.reveal()
+}
+
+// openVote checks the opening sent by a participant against the commitment
+// received before and flags the participant if they don't match.
+func (s *EstimationSession) openVote(id peer.ID, encoded string) {
+	p, ok := s.participants[id]
+	if !ok {
+		return
+	}
+	p.pendingOpening = ""
+	if p.commitment == "" {
+		// the opening overtook the commitment, check it when it arrives
+		p.pendingOpening = encoded
+		return
+	}
+
+	o, err := commitment.Decode(encoded)
+	if err != nil || !commitment.Verify(p.commitment, o) {
+		p.vote = ""
+		p.opening = ""
+		p.mismatch = true
+	} else {
+		p.vote = o.Vote
+		p.opening = encoded
+		p.mismatch = false
+	}
+}
+
+func (s *EstimationSession) reveal() error {
+	if !s.revealed {
+		s.revealedAt = time.Now()
+	}
+	s.revealed = true
+	return s.sendOpening()
+}
+
+func (s *EstimationSession) clearVotes() {
+	s.completeRound()
+	for _, p := range s.participants {
+		p.commitment = ""
+		p.pendingOpening = ""
+		p.opening = ""
+		p.vote = ""
+		p.mismatch = false
+	}
+	s.revealed = false
+	s.recorded = false
+	s.opening = nil
+	s.openingSent = false
+}
+
+// sendOpening publishes the opening of our commitment, if we voted in this
+// round and didn't reveal it yet.
+func (s *EstimationSession) sendOpening() error {
+	if s.opening == nil || s.openingSent {
+		return nil
+	}
+	s.openingSent = true
+	return s.pub.Publish(chatroom.RevealVote, s.round, chatroom.OpeningPayload{Opening: s.opening.Encode()})
+}
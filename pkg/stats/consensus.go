@@ -0,0 +1,70 @@
+package stats
+
+import "sort"
+
+// Verdict tells how close the votes of a round are.
+type Verdict string
+
+const (
+	// Consensus is when everyone played the same card.
+	Consensus Verdict = "consensus"
+	// Close is when the votes are in adjacent cards.
+	Close Verdict = "close"
+	// Split is when the votes are further apart, and it's worth to discuss
+	// and vote again.
+	Split Verdict = "no consensus"
+)
+
+// MaxOutlierDistance is how many cards a vote can be from the median before
+// it is an outlier.
+const MaxOutlierDistance = 1
+
+// Agreement returns the verdict for the votes given as positions of the
+// cards in the deck.
+func Agreement(positions []int) Verdict {
+	if len(positions) == 0 {
+		return Consensus
+	}
+
+	min, max := positions[0], positions[0]
+	for _, p := range positions {
+		if p < min {
+			min = p
+		}
+		if p > max {
+			max = p
+		}
+	}
+	switch max - min {
+	case 0:
+		return Consensus
+	case 1:
+		return Close
+	default:
+		return Split
+	}
+}
+
+// Outliers returns the indexes of the positions further than
+// MaxOutlierDistance cards from the median. There are no outliers when the
+// votes are close.
+func Outliers(positions []int) []int {
+	if Agreement(positions) != Split {
+		return nil
+	}
+
+	sorted := make([]float64, 0, len(positions))
+	for _, p := range positions {
+		sorted = append(sorted, float64(p))
+	}
+	sort.Float64s(sorted)
+	median := Median(sorted)
+
+	var outliers []int
+	for i, p := range positions {
+		if d := float64(p) - median; d > MaxOutlierDistance || d < -MaxOutlierDistance {
+			outliers = append(outliers, i)
+		}
+	}
+	return outliers
+}
@@ -0,0 +1,91 @@
+// Package stats computes the statistics of the votes revealed in a round.
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary describes a set of votes.
+type Summary struct {
+	Count  int
+	Mean   float64
+	Median float64
+	// Mode has the most voted values, more than one on a tie.
+	Mode   []float64
+	Min    float64
+	Max    float64
+	StdDev float64
+}
+
+// Summarize computes the summary of the values. It returns false when there
+// are no values, as there's nothing to summarize.
+func Summarize(values []float64) (Summary, bool) {
+	if len(values) == 0 {
+		return Summary{}, false
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	s := Summary{
+		Count:  len(sorted),
+		Mean:   mean(sorted),
+		Median: Median(sorted),
+		Mode:   mode(sorted),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+	}
+	s.StdDev = stdDev(sorted, s.Mean)
+	return s, true
+}
+
+// Median returns the median of values sorted in ascending order.
+func Median(sorted []float64) float64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stdDev is the population standard deviation, as the votes are all the
+// votes of the round and not a sample.
+func stdDev(values []float64, mean float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+func mode(sorted []float64) []float64 {
+	var modes []float64
+	best := 0
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j] == sorted[i] {
+			j++
+		}
+		switch count := j - i; {
+		case count > best:
+			best = count
+			modes = []float64{sorted[i]}
+		case count == best:
+			modes = append(modes, sorted[i])
+		}
+		i = j
+	}
+	return modes
+}
@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+)
+
+// votes returns the values and positions of the cards in the fibonacci
+// deck, skipping the ones without a value like the session does.
+func votes(cards ...string) ([]float64, []int) {
+	var values []float64
+	var positions []int
+	for _, c := range cards {
+		v, ok := deck.Fibonacci.Value(c)
+		if !ok {
+			continue
+		}
+		pos, _ := deck.Fibonacci.Position(c)
+		values = append(values, v)
+		positions = append(positions, pos)
+	}
+	return values, positions
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name   string
+		cards  []string
+		want   Summary
+		wantOK bool
+	}{
+		{
+			name: "no votes",
+		},
+		{
+			name:  "only cards without value",
+			cards: []string{"?", "☕"},
+		},
+		{
+			name:   "single vote",
+			cards:  []string{"5"},
+			want:   Summary{Count: 1, Mean: 5, Median: 5, Mode: []float64{5}, Min: 5, Max: 5},
+			wantOK: true,
+		},
+		{
+			name:   "cards without value are ignored",
+			cards:  []string{"5", "?", "3", "☕"},
+			want:   Summary{Count: 2, Mean: 4, Median: 4, Mode: []float64{3, 5}, Min: 3, Max: 5, StdDev: 1},
+			wantOK: true,
+		},
+		{
+			name:   "most voted card",
+			cards:  []string{"8", "2", "1", "2"},
+			want:   Summary{Count: 4, Mean: 3.25, Median: 2, Mode: []float64{2}, Min: 1, Max: 8, StdDev: math.Sqrt(7.6875)},
+			wantOK: true,
+		},
+		{
+			name:   "tie for the most voted card",
+			cards:  []string{"8", "1", "3", "3", "1", "8"},
+			want:   Summary{Count: 6, Mean: 4, Median: 3, Mode: []float64{1, 3, 8}, Min: 1, Max: 8, StdDev: math.Sqrt(26.0 / 3)},
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := votes(tt.cards...)
+			got, ok := Summarize(values)
+			if ok != tt.wantOK {
+				t.Fatalf("Summarize() ok = %t, want %t", ok, tt.wantOK)
+			}
+			if math.Abs(got.StdDev-tt.want.StdDev) < 1e-9 {
+				got.StdDev = tt.want.StdDev
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeKeepsTheValues(t *testing.T) {
+	values := []float64{8, 1, 3}
+	Summarize(values)
+	if !reflect.DeepEqual(values, []float64{8, 1, 3}) {
+		t.Errorf("Summarize() sorted the values: %v", values)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		sorted []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{5}, 5},
+		{[]float64{1, 2, 13}, 2},
+		{[]float64{1, 2, 3, 13}, 2.5},
+	}
+	for _, tt := range tests {
+		if got := Median(tt.sorted); got != tt.want {
+			t.Errorf("Median(%v) = %v, want %v", tt.sorted, got, tt.want)
+		}
+	}
+}
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		sorted []float64
+		want   []float64
+	}{
+		{nil, nil},
+		{[]float64{5}, []float64{5}},
+		{[]float64{1, 2, 2, 3}, []float64{2}},
+		{[]float64{1, 2, 3}, []float64{1, 2, 3}},
+		{[]float64{1, 1, 2, 3, 3}, []float64{1, 3}},
+	}
+	for _, tt := range tests {
+		if got := mode(tt.sorted); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mode(%v) = %v, want %v", tt.sorted, got, tt.want)
+		}
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{[]float64{5}, 0},
+		{[]float64{3, 3, 3}, 0},
+		{[]float64{3, 5}, 1},
+		{[]float64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+	}
+	for _, tt := range tests {
+		if got := stdDev(tt.values, mean(tt.values)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("stdDev(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestAgreement(t *testing.T) {
+	tests := []struct {
+		name  string
+		cards []string
+		want  Verdict
+	}{
+		{"no votes", nil, Consensus},
+		{"only cards without value", []string{"?", "☕"}, Consensus},
+		{"single vote", []string{"5"}, Consensus},
+		{"same card", []string{"5", "5", "?"}, Consensus},
+		{"adjacent cards", []string{"3", "5", "5"}, Close},
+		{"cards apart", []string{"2", "5"}, Split},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, positions := votes(tt.cards...)
+			if got := Agreement(positions); got != tt.want {
+				t.Errorf("Agreement(%v) = %q, want %q", tt.cards, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutliers(t *testing.T) {
+	tests := []struct {
+		name  string
+		cards []string
+		want  []int
+	}{
+		{"no votes", nil, nil},
+		{"single vote", []string{"5"}, nil},
+		{"close votes", []string{"3", "5", "3"}, nil},
+		{"one far from the others", []string{"3", "3", "5", "20"}, []int{3}},
+		{"both sides of the median", []string{"1", "5", "5", "5", "40"}, []int{0, 4}},
+		{"cards without value are skipped", []string{"5", "?", "5", "☕", "40"}, []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, positions := votes(tt.cards...)
+			if got := Outliers(positions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Outliers(%v) = %v, want %v", tt.cards, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,45 +2,84 @@ package ui
 
 import (
 	"fmt"
-	"strconv"
 	"time"
 
+	"github.com/renato0307/p2p-estimator/pkg/activity"
 	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/history"
+	"github.com/renato0307/p2p-estimator/pkg/jira"
+	"github.com/renato0307/p2p-estimator/pkg/session"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 var baseStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.NormalBorder()).
 	BorderForeground(lipgloss.Color("240"))
 
+var statusStyle = lipgloss.NewStyle().
+	MarginLeft(2).
+	Foreground(lipgloss.Color("240"))
+
 type model struct {
-	participants map[string]participant
-	cr           *chatroom.ChatRoom
+	session *session.EstimationSession
+	cr      *chatroom.ChatRoom
 
 	menu   list.Model
 	choice string
 	table  table.Model
 
+	deck       deck.Deck
+	deckPicker list.Model
+	editDeck   bool
+
 	description     textinput.Model
 	editDescription bool
-	showDescription bool
 
-	showVotes bool
+	backlogInput     textinput.Model
+	editBacklogInput bool
+
+	handOverInput     textinput.Model
+	editHandOverInput bool
+
+	history *history.Store
+
+	jira        *jira.Client
+	jiraKey     textinput.Model
+	editJiraKey bool
+	// jiraIssue is the issue loaded into jiraDescription, forgotten when
+	// the room moves to another description
+	jiraIssue       string
+	jiraDescription string
+
+	activity     *activity.Log
+	activityView viewport.Model
+	activityLog  []string
+
+	connectivity string
+	notice       string
+	// failure is the last error, until the action that failed or another
+	// one succeeds
+	failure      string
+	failedAction string
 }
 
 type tickMsg time.Time
 type receiveMsg *chatroom.ChatMessage
+type connectivityMsg string
+type noticeMsg string
 
 func (m model) Init() tea.Cmd {
 	return tea.Batch(
 		tickCmd(),
 		m.receiveMsgCmd(),
+		m.receiveActivityCmd(),
 	)
 }
 
@@ -51,20 +90,77 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "enter":
-			if m.editDescription {
-				m.updateDescription(true)
-			} else {
-				m.handleMenuEvents()
+			switch {
+			case m.editDescription:
+				cmd = m.updateDescription()
+			case m.editJiraKey:
+				cmd = m.loadIssue()
+			case m.editBacklogInput:
+				cmd = m.loadBacklog()
+			case m.editHandOverInput:
+				cmd = m.handOver()
+			case m.editDeck:
+				cmd = m.changeDeck()
+			default:
+				cmd = m.handleMenuEvents()
 			}
-			return m, nil
-		case "q", "ctrl+c":
+			m.syncDescription()
+			m.syncMenu()
+			m.refreshTable()
+			return m, cmd
+		case "esc":
+			if m.editing() {
+				m.cancelEdit()
+				return m, nil
+			}
+		case "q":
+			// q can be typed in the inputs
+			if !m.editing() {
+				return m, tea.Quit
+			}
+		case "ctrl+c":
 			return m, tea.Quit
 		}
+		if m.scrollActivity(msg.String()) {
+			return m, nil
+		}
+	case tea.WindowSizeMsg:
+		if msg.Width > activityWidth/2 {
+			m.activityView.Width = msg.Width - 4
+		}
+	case activityMsg:
+		m.addActivity(msg)
+		return m, m.receiveActivityCmd()
+	case actionMsg:
+		cmd = m.attempt(msg)
+		m.syncDescription()
+		m.syncMenu()
+		m.refreshTable()
+		return m, cmd
 	case receiveMsg:
 		m.handleNewMessage(msg)
+		m.syncMenu()
+		m.refreshTable()
 		return m, m.receiveMsgCmd()
+	case jiraIssueMsg:
+		m.handleJiraIssue(msg)
+		return m, nil
+	case jiraUpdatedMsg:
+		m.handleJiraUpdated(msg)
+		return m, nil
+	case connectivityMsg:
+		m.connectivity = string(msg)
+		return m, nil
+	case noticeMsg:
+		m.notice = string(msg)
+		return m, nil
 	case tickMsg:
-		m.sendHeartbeat()
+		// the next tick tries again
+		if err := m.session.Tick(); err != nil {
+			m.fail(tickAction, err)
+		} else if m.failedAction == tickAction {
+			m.failure, m.failedAction = "", ""
+		}
 		cmd = m.updateParticipantsTable(msg)
 		return m, tea.Batch(tickCmd(), cmd)
 	}
@@ -74,38 +170,123 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	if m.editJiraKey {
+		m.jiraKey, cmd = m.jiraKey.Update(msg)
+		return m, cmd
+	}
+
+	if m.editBacklogInput {
+		m.backlogInput, cmd = m.backlogInput.Update(msg)
+		return m, cmd
+	}
+
+	if m.editHandOverInput {
+		m.handOverInput, cmd = m.handOverInput.Update(msg)
+		return m, cmd
+	}
+
+	if m.editDeck {
+		m.deckPicker, cmd = m.deckPicker.Update(msg)
+		return m, cmd
+	}
+
 	cmdList := m.updateMenu(msg)
 	return m, cmdList
 }
 
+// editing tells if the keys go to one of the inputs or the deck picker,
+// instead of the menu.
+func (m model) editing() bool {
+	return m.editDescription || m.editJiraKey || m.editBacklogInput || m.editHandOverInput || m.editDeck
+}
+
+// cancelEdit leaves the input or the deck picker without changing
+// anything.
+func (m *model) cancelEdit() {
+	m.editDescription, m.editJiraKey, m.editBacklogInput, m.editHandOverInput, m.editDeck = false, false, false, false, false
+	m.description.Blur()
+	m.jiraKey.Blur()
+	m.backlogInput.Blur()
+	m.handOverInput.Blur()
+	m.syncDescription()
+}
+
 func (m model) View() string {
+	state := m.session.State()
+
 	header := fmt.Sprintf("\n  Welcome to <%s>\n", m.cr.RoomName)
+	if m.connectivity != "" {
+		header += statusStyle.Render("🌐 "+m.connectivity) + "\n"
+	}
+	header += statusStyle.Render("🃏 "+state.Deck.Name) + "\n"
+	if m.cr.Reconnecting() {
+		header += statusStyle.Render("🔌 reconnecting to the room...") + "\n"
+	}
+	if warning := versionWarning(m.cr.Versions()); warning != "" {
+		header += statusStyle.Render(warning) + "\n"
+	}
 	t := m.table.View()
 	tableRendered := baseStyle.Render(t)
 
 	descriptionRendered := "> description not set <"
-	if m.editDescription || m.showDescription {
+	if m.editDescription || state.Description != "" {
 		descriptionRendered = m.description.View()
 	}
 
-	averageString := ""
-	if m.showVotes {
-		avg := m.calculateVotesAverage()
-		averageString = fmt.Sprintf("Average: %s\n", strconv.FormatFloat(float64(avg), 'f', 2, 32))
+	statsRendered := ""
+	var current *session.Statistics
+	if st, ok := m.session.Statistics(); ok {
+		statsRendered = statsView(state.Deck, st)
+		current = &st
 	}
 
 	leftSize := lipgloss.JoinVertical(lipgloss.Center, tableRendered)
-	leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, averageString)
+	leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, statsRendered)
 	leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, descriptionRendered)
-	return header + "\n" + lipgloss.JoinHorizontal(lipgloss.Top, leftSize, m.menu.View())
+	if m.editJiraKey {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, m.jiraKey.View())
+	}
+	if m.editBacklogInput {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, m.backlogInput.View())
+	}
+	if roundsRendered := roundsView(state, current); roundsRendered != "" {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, baseStyle.Render(roundsRendered))
+	}
+	if backlogRendered := backlogView(state); backlogRendered != "" {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, baseStyle.Render(backlogRendered))
+	}
+	if m.editHandOverInput {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, m.handOverInput.View())
+	}
+	if m.notice != "" {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, statusStyle.Render(m.notice))
+	}
+	if m.failure != "" {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, errorStyle.Render(m.failure))
+	}
+	if requestsRendered := requestsView(state); requestsRendered != "" {
+		leftSize = lipgloss.JoinVertical(lipgloss.Center, leftSize, requestsRendered)
+	}
+	menuRendered := m.menu.View()
+	if m.editDeck {
+		menuRendered = m.deckPicker.View()
+	}
+	view := header + "\n" + lipgloss.JoinHorizontal(lipgloss.Top, leftSize, menuRendered)
+	if pane := m.activityPane(); pane != "" {
+		view += "\n" + pane
+	}
+	return view
 }
 
-func (m *model) sendHeartbeat() tea.Cmd {
-	err := m.cr.Publish(chatroom.Heartbeat, "")
-	if err != nil {
-		panic(err)
+// versionWarning tells when peers run another version of the protocol.
+func versionWarning(oldest, newest int) string {
+	switch {
+	case newest > chatroom.Version:
+		return "⚠️ some peers run a newer version, please upgrade"
+	case oldest != 0 && oldest < chatroom.Version:
+		return "⚠️ some peers run an older version and can't read our messages"
 	}
-	return nil
+	return ""
 }
 
 func tickCmd() tea.Cmd {
@@ -119,18 +300,37 @@ type EstimatorUI struct {
 	m *model
 }
 
-func NewEstimationUI(cr *chatroom.ChatRoom) *EstimatorUI {
+// Options holds the optional features of the UI.
+type Options struct {
+	// Decks the room can switch between.
+	Decks []deck.Deck
+	// Jira is used to load stories and save their estimates, if set.
+	Jira *jira.Client
+	// History is exported from the menu, if set.
+	History *history.Store
+	// Activity is shown in a pane below the room, if set.
+	Activity *activity.Log
+}
+
+// NewEstimationUI creates the text UI for the estimation session, which
+// is updated with the messages received in the chat room.
+func NewEstimationUI(cr *chatroom.ChatRoom, s *session.EstimationSession, opts Options) *EstimatorUI {
+	d := s.State().Deck
 	m := model{
-		menu:        NewMenu(),
-		table:       NewTable(),
-		description: NewDescriptionInput(),
-		cr:          cr,
-		participants: map[string]participant{
-			shortID(cr.Self): {
-				id:   cr.Self,
-				nick: cr.Nick + " (you)",
-			},
-		},
+		session:       s,
+		deck:          d,
+		deckPicker:    NewDeckPicker(opts.Decks),
+		jira:          opts.Jira,
+		history:       opts.History,
+		jiraKey:       NewJiraKeyInput(),
+		backlogInput:  NewBacklogInput(),
+		handOverInput: NewHandOverInput(),
+		menu:          NewMenu(d),
+		table:         NewTable(),
+		description:   NewDescriptionInput(),
+		activity:      opts.Activity,
+		activityView:  NewActivityView(),
+		cr:            cr,
 	}
 	ui := EstimatorUI{
 		p: tea.NewProgram(m),
@@ -145,13 +345,12 @@ func (ui *EstimatorUI) Run() error {
 	return err
 }
 
-func (m *model) self() *participant {
-	peer := m.participants[shortID(m.cr.Self)]
-	return &peer
+// SetConnectivity updates the connectivity status shown below the header.
+func (ui *EstimatorUI) SetConnectivity(status string) {
+	ui.p.Send(connectivityMsg(status))
 }
 
-// shortID returns the last 8 chars of a base58-encoded peer id.
-func shortID(p peer.ID) string {
-	pretty := p.Pretty()
-	return pretty[len(pretty)-8:]
+// Notify shows a notice to the user.
+func (ui *EstimatorUI) Notify(notice string) {
+	ui.p.Send(noticeMsg(notice))
 }
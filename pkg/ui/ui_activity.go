@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/renato0307/p2p-estimator/pkg/activity"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// activityLines is the height of the activity pane.
+const activityLines = 6
+
+// activityWidth is the width of the activity pane until we know the size
+// of the terminal.
+const activityWidth = 80
+
+// maxActivity is the number of events kept, the older ones are dropped.
+const maxActivity = 500
+
+var activityTimeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+
+type activityMsg activity.Event
+
+func NewActivityView() viewport.Model {
+	return viewport.New(activityWidth, activityLines)
+}
+
+func (m *model) receiveActivityCmd() tea.Cmd {
+	if m.activity == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return activityMsg(<-m.activity.Events())
+	}
+}
+
+// addActivity shows an event in the activity pane. It keeps showing the
+// newest events, unless the user scrolled up to read older ones.
+func (m *model) addActivity(ev activityMsg) {
+	style := statusStyle.Copy().MarginLeft(0)
+	if ev.Kind == activity.Error {
+		style = errorStyle.Copy().MarginLeft(0)
+	}
+	line := activityTimeStyle.Render(ev.Time.Format("15:04:05")) + " " + style.Render(ev.Text)
+
+	m.activityLog = append(m.activityLog, line)
+	if len(m.activityLog) > maxActivity {
+		m.activityLog = m.activityLog[len(m.activityLog)-maxActivity:]
+	}
+
+	follow := m.activityView.AtBottom()
+	m.activityView.SetContent(strings.Join(m.activityLog, "\n"))
+	if follow {
+		m.activityView.GotoBottom()
+	}
+}
+
+// scrollActivity handles the keys that scroll the activity pane.
+func (m *model) scrollActivity(key string) bool {
+	switch key {
+	case "pgup":
+		m.activityView.HalfViewUp()
+	case "pgdown":
+		m.activityView.HalfViewDown()
+	default:
+		return false
+	}
+	return true
+}
+
+// activityPane shows the recent activity, if there is any.
+func (m model) activityPane() string {
+	if len(m.activityLog) == 0 {
+		return ""
+	}
+	title := statusStyle.Render("Activity (pgup/pgdown to scroll)")
+	return title + "\n" + baseStyle.Render(m.activityView.View())
+}
@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/renato0307/p2p-estimator/pkg/backlog"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// backlogLines is the number of stories shown around the current one.
+const backlogLines = 7
+
+var (
+	storyStyle        = lipgloss.NewStyle().PaddingLeft(2)
+	currentStoryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
+)
+
+func NewBacklogInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "File with stories, or stories separated by ; (esc to cancel)"
+	ti.CharLimit = 4096
+	ti.Width = 40
+
+	return ti
+}
+
+func (m *model) editBacklog() {
+	m.editBacklogInput = true
+	m.backlogInput.SetValue("")
+	m.backlogInput.Focus()
+}
+
+// loadBacklog loads the stories from the file entered, or the ones pasted.
+func (m *model) loadBacklog() tea.Cmd {
+	m.editBacklogInput = false
+	m.backlogInput.Blur()
+
+	value := strings.TrimSpace(m.backlogInput.Value())
+	if value == "" {
+		return nil
+	}
+
+	var stories []backlog.Story
+	var err error
+	if fileExists(value) {
+		stories, err = backlog.Load(value)
+	} else {
+		stories = backlog.ParseText(strings.ReplaceAll(value, ";", "\n"))
+		if len(stories) == 0 {
+			err = backlog.ErrEmpty
+		}
+	}
+	if err != nil {
+		m.notice = err.Error()
+		return nil
+	}
+
+	done := fmt.Sprintf("loaded %d stories", len(stories))
+	return m.do("load the backlog", done, func() error {
+		return m.session.LoadBacklog(stories)
+	})
+}
+
+func (m *model) moveStory(move func() error) tea.Cmd {
+	m.notice = ""
+	m.jiraIssue = ""
+	return m.do("move to another story", "", move)
+}
+
+// backlogView shows the stories around the one being estimated.
+func backlogView(state session.State) string {
+	if len(state.Backlog) == 0 {
+		return ""
+	}
+
+	first := state.Current - backlogLines/2
+	if first > len(state.Backlog)-backlogLines {
+		first = len(state.Backlog) - backlogLines
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	lines := []string{fmt.Sprintf("Story %d of %d", state.Current+1, len(state.Backlog))}
+	for i := first; i < len(state.Backlog) && i < first+backlogLines; i++ {
+		story := state.Backlog[i]
+		line := fmt.Sprintf("%d. %s", i+1, truncate(story.String(), 50))
+		if story.Estimate != "" {
+			line += fmt.Sprintf(" (%s)", story.Estimate)
+		}
+		if i == state.Current {
+			line = currentStoryStyle.Render("> " + line)
+		} else {
+			line = storyStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// fileExists is used to tell file paths from stories pasted.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func truncate(s string, size int) string {
+	runes := []rune(s)
+	if len(runes) <= size {
+		return s
+	}
+	return string(runes[:size-1]) + "…"
+}
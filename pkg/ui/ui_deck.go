@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// deckItem is an item of the deck picker.
+type deckItem struct {
+	deck deck.Deck
+}
+
+func (i deckItem) FilterValue() string { return "" }
+
+func (i deckItem) title() string {
+	return i.deck.Name + " (" + strings.Join(i.deck.Cards, " ") + ")"
+}
+
+func NewDeckPicker(decks []deck.Deck) list.Model {
+	items := make([]list.Item, 0, len(decks))
+	for _, d := range decks {
+		items = append(items, deckItem{deck: d})
+	}
+
+	const defaultWidth = 50
+
+	l := list.New(items, itemDelegate{}, defaultWidth, len(items)+6)
+	l.Title = "Which deck? (esc to cancel)"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.Styles.Title = titleStyle
+	l.Styles.PaginationStyle = paginationStyle
+	l.Styles.HelpStyle = helpStyle
+
+	return l
+}
+
+// pickDeck shows the decks available, with the one in use selected.
+func (m *model) pickDeck() {
+	m.editDeck = true
+	for i, item := range m.deckPicker.Items() {
+		if item.(deckItem).deck.Name == m.deck.Name {
+			m.deckPicker.Select(i)
+			break
+		}
+	}
+}
+
+// changeDeck moves the room to the deck picked.
+func (m *model) changeDeck() tea.Cmd {
+	m.editDeck = false
+
+	i, ok := m.deckPicker.SelectedItem().(deckItem)
+	if !ok || i.deck.Name == m.deck.Name {
+		return nil
+	}
+	return m.do("change the deck", "", func() error {
+		return m.session.SetDeck(i.deck)
+	})
+}
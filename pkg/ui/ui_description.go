@@ -1,35 +1,35 @@
 package ui
 
 import (
-	"github.com/renato0307/p2p-estimator/pkg/chatroom"
-
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func NewDescriptionInput() textinput.Model {
 	ti := textinput.New()
-	ti.Placeholder = "What are we estimating?"
+	ti.Placeholder = "What are we estimating? (esc to cancel)"
 	ti.CharLimit = 156
 	ti.Width = 20
 
 	return ti
 }
 
-func (m *model) updateDescription(sendDescription bool) {
-	m.showDescription = m.description.Value() != ""
+func (m *model) updateDescription() tea.Cmd {
 	m.editDescription = false
 	m.description.Blur()
-	if !sendDescription {
-		return
-	}
-	m.sendDescription()
+	m.jiraIssue = ""
+
+	description := m.description.Value()
+	return m.do("set the description", "", func() error {
+		return m.session.SetDescription(description)
+	})
 }
 
-func (m *model) sendDescription() tea.Cmd {
-	err := m.cr.Publish(chatroom.SetDescription, m.description.Value())
-	if err != nil {
-		panic(err)
+// syncDescription shows the description set by other peers, unless we are
+// editing it.
+func (m *model) syncDescription() {
+	if m.editDescription {
+		return
 	}
-	return nil
+	m.description.SetValue(m.session.State().Description)
 }
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/backlog"
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MaxRetries is how many times an action failing with a transient error
+// is tried again before giving up.
+const MaxRetries = 3
+
+// tickAction is what the UI does periodically, it fails when the room
+// can't be reached.
+const tickAction = "keep in touch with the room"
+
+// RetryBackoff is how long we wait before the first retry, it doubles on
+// each one.
+const RetryBackoff = time.Second
+
+var errorStyle = lipgloss.NewStyle().
+	MarginLeft(2).
+	Foreground(lipgloss.Color("160"))
+
+// actionMsg runs an action of the user again after it failed.
+type actionMsg struct {
+	name    string
+	run     func() error
+	done    string
+	attempt int
+}
+
+// do runs an action of the user, named for the error shown if it fails.
+// The done notice is shown when it succeeds. Actions failing with a
+// transient error are tried again later, which is safe since the session
+// only changes its state once the room was told.
+func (m *model) do(name, done string, run func() error) tea.Cmd {
+	return m.attempt(actionMsg{name: name, run: run, done: done})
+}
+
+func (m *model) attempt(a actionMsg) tea.Cmd {
+	err := a.run()
+	switch {
+	case err == nil:
+		m.failure, m.failedAction = "", ""
+		if a.done != "" {
+			m.notice = a.done
+		}
+		return nil
+	case expected(err):
+		m.notice = err.Error()
+		return nil
+	case transient(err) && a.attempt < MaxRetries:
+		delay := RetryBackoff << a.attempt
+		m.failure = fmt.Sprintf("❌ could not %s, retrying in %s: %s", a.name, delay, err)
+		m.failedAction = a.name
+		a.attempt++
+		return tea.Tick(delay, func(time.Time) tea.Msg {
+			return a
+		})
+	}
+	m.fail(a.name, err)
+	return nil
+}
+
+// fail shows an error in the notification area.
+func (m *model) fail(name string, err error) {
+	m.failure = fmt.Sprintf("❌ could not %s: %s", name, err)
+	m.failedAction = name
+}
+
+// expected tells if the error is the normal outcome of an action, which is
+// shown as a notice: requests sent to the facilitator, nothing to move
+// to, etc.
+func expected(err error) bool {
+	return errors.Is(err, session.ErrRequested) ||
+		errors.Is(err, session.ErrNotFacilitator) ||
+		errors.Is(err, session.ErrNotRevealed) ||
+		errors.Is(err, session.ErrNoStory) ||
+		errors.Is(err, backlog.ErrEmpty)
+}
+
+// transient tells if an action can succeed when tried again later.
+func transient(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, chatroom.ErrOutboxFull) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/renato0307/p2p-estimator/pkg/session"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func NewHandOverInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "Nick of the new facilitator (esc to cancel)"
+	ti.CharLimit = 64
+	ti.Width = 20
+
+	return ti
+}
+
+func (m *model) editHandOver() {
+	if m.session.State().Facilitator != m.cr.Self {
+		m.notice = session.ErrNotFacilitator.Error()
+		return
+	}
+	m.editHandOverInput = true
+	m.handOverInput.SetValue("")
+	m.handOverInput.Focus()
+}
+
+// handOver gives the facilitator role to the participant with the nick
+// entered.
+func (m *model) handOver() tea.Cmd {
+	m.editHandOverInput = false
+	m.handOverInput.Blur()
+
+	nick := strings.TrimSpace(m.handOverInput.Value())
+	if nick == "" {
+		return nil
+	}
+
+	p, ok := m.session.ParticipantByNick(nick)
+	if !ok {
+		m.notice = fmt.Sprintf("there is nobody called %s in the room", nick)
+		return nil
+	}
+	done := fmt.Sprintf("%s is now the facilitator", p.Nick)
+	return m.do("hand over", done, func() error {
+		return m.session.HandOver(p.ID)
+	})
+}
+
+// requestsView shows the facilitator what the others asked for.
+func requestsView(state session.State) string {
+	if len(state.Requests) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(state.Requests))
+	for _, r := range state.Requests {
+		lines = append(lines, fmt.Sprintf("%s %s asks to %s", r.At.Format("15:04:05"), r.Nick, r.Action))
+	}
+	return statusStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
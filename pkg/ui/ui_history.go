@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/history"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// exportHistory writes the rounds estimated in this room to a markdown file
+// in the current directory.
+func (m *model) exportHistory() {
+	if m.history == nil {
+		m.notice = "history is disabled"
+		return
+	}
+
+	rounds, err := m.history.Rounds(m.cr.RoomName)
+	if err != nil {
+		m.notice = err.Error()
+		return
+	}
+
+	name := fmt.Sprintf("estimation-%s-%s.md",
+		unsafeFileChars.ReplaceAllString(m.cr.RoomName, "_"),
+		time.Now().Format("20060102-150405"))
+	f, err := os.Create(name)
+	if err != nil {
+		m.notice = err.Error()
+		return
+	}
+	defer f.Close()
+
+	if err := history.Export(f, rounds, history.Markdown); err != nil {
+		m.notice = err.Error()
+		return
+	}
+	m.notice = fmt.Sprintf("%d rounds exported to %s", len(rounds), name)
+}
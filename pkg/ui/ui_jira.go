@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/renato0307/p2p-estimator/pkg/jira"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type jiraIssueMsg struct {
+	issue *jira.Issue
+	err   error
+}
+
+type jiraUpdatedMsg struct {
+	key      string
+	estimate string
+	err      error
+}
+
+func NewJiraKeyInput() textinput.Model {
+	ti := textinput.New()
+	ti.Placeholder = "Jira issue key, e.g. PROJ-123 (esc to cancel)"
+	ti.CharLimit = 32
+	ti.Width = 20
+
+	return ti
+}
+
+// updateJira writes the estimate to the Jira issue loaded, once the votes
+// are revealed. Otherwise it asks for the key of the issue to load.
+func (m *model) updateJira() tea.Cmd {
+	if m.jira == nil {
+		m.notice = jira.ErrNotConfigured.Error()
+		return nil
+	}
+
+	state := m.session.State()
+	if state.Description != m.jiraDescription {
+		m.jiraIssue = ""
+	}
+	if m.jiraIssue != "" && state.Revealed {
+		return m.writeEstimate()
+	}
+
+	m.editJiraKey = true
+	m.jiraKey.SetValue("")
+	m.jiraKey.Focus()
+	return nil
+}
+
+// loadIssue loads the summary of the issue with the key entered.
+func (m *model) loadIssue() tea.Cmd {
+	m.editJiraKey = false
+	m.jiraKey.Blur()
+
+	key := strings.TrimSpace(m.jiraKey.Value())
+	if key == "" {
+		return nil
+	}
+
+	m.notice = fmt.Sprintf("loading %s from jira...", key)
+	client := m.jira
+	return func() tea.Msg {
+		issue, err := client.GetIssue(context.Background(), key)
+		return jiraIssueMsg{issue: issue, err: err}
+	}
+}
+
+func (m *model) writeEstimate() tea.Cmd {
+	state := m.session.State()
+	estimate, ok := m.session.Estimate()
+	if !ok {
+		m.notice = "there are no votes to send to jira"
+		return nil
+	}
+	points, ok := state.Deck.Value(estimate)
+	if !ok || !state.Deck.Numeric() {
+		m.notice = fmt.Sprintf("the %s deck has no story points to send to jira", state.Deck.Name)
+		return nil
+	}
+
+	key := m.jiraIssue
+	m.notice = fmt.Sprintf("updating %s with %s...", key, estimate)
+	client := m.jira
+	return func() tea.Msg {
+		err := client.SetStoryPoints(context.Background(), key, points)
+		return jiraUpdatedMsg{key: key, estimate: estimate, err: err}
+	}
+}
+
+func (m *model) handleJiraIssue(msg jiraIssueMsg) {
+	if msg.err != nil {
+		m.notice = msg.err.Error()
+		return
+	}
+
+	description := fmt.Sprintf("%s: %s", msg.issue.Key, msg.issue.Summary)
+	m.description.SetValue(description)
+	m.notice = ""
+	m.updateDescription()
+	m.jiraIssue = msg.issue.Key
+	m.jiraDescription = description
+	// unless setting the description was requested to the facilitator
+	if m.notice == "" {
+		m.notice = fmt.Sprintf("loaded %s, update jira after the reveal to save the estimate", msg.issue.Key)
+	}
+}
+
+func (m *model) handleJiraUpdated(msg jiraUpdatedMsg) {
+	if msg.err != nil {
+		m.notice = msg.err.Error()
+		return
+	}
+	m.notice = fmt.Sprintf("%s estimated with %s", msg.key, msg.estimate)
+}
@@ -3,6 +3,9 @@ package ui
 import (
 	"fmt"
 	"io"
+	"reflect"
+
+	"github.com/renato0307/p2p-estimator/pkg/deck"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,29 +24,28 @@ const (
 	OPTION_SET_DESCRIPTION = "Set description 🖍"
 	OPTION_CLEAR_VOTES     = "Clear votes 🗑"
 	OPTION_SHOW_VOTES      = "Show votes 🔎"
+	OPTION_REVOTE          = "Vote again 🔁"
+	OPTION_CHANGE_DECK     = "Change deck 🃏"
+	OPTION_LOAD_BACKLOG    = "Load backlog 📋"
+	OPTION_NEXT_STORY      = "Next story ⏭"
+	OPTION_PREVIOUS_STORY  = "Previous story ⏮"
+	OPTION_SKIP_STORY      = "Skip story ⏩"
 	OPTION_UPDATE_JIRA     = "Update jira 🧙"
+	OPTION_EXPORT_HISTORY  = "Export history 💾"
+	OPTION_HAND_OVER       = "Hand over facilitator 👑"
 )
 
 type item string
 type itemDelegate struct{}
 
-func NewMenu() list.Model {
-	items := []list.Item{
-		item(OPTION_SET_DESCRIPTION),
-		item(OPTION_CLEAR_VOTES),
-		item(OPTION_SHOW_VOTES),
-		item(OPTION_UPDATE_JIRA),
-		item("0 points"),
-		item("1 point"),
-		item("2 points"),
-		item("3 points"),
-		item("5 points"),
-		item("8 points"),
-		item("13 points"),
-		item("20 points"),
-		item("40 points"),
-		item("No clue 🤷"),
-	}
+// cardItem is a menu item to vote with a card of the deck.
+type cardItem struct {
+	card  string
+	title string
+}
+
+func NewMenu(d deck.Deck) list.Model {
+	items := menuItems(d)
 
 	const defaultWidth = 30
 
@@ -58,17 +60,45 @@ func NewMenu() list.Model {
 	return l
 }
 
+func menuItems(d deck.Deck) []list.Item {
+	items := []list.Item{
+		item(OPTION_SET_DESCRIPTION),
+		item(OPTION_CLEAR_VOTES),
+		item(OPTION_SHOW_VOTES),
+		item(OPTION_REVOTE),
+		item(OPTION_CHANGE_DECK),
+		item(OPTION_LOAD_BACKLOG),
+		item(OPTION_NEXT_STORY),
+		item(OPTION_PREVIOUS_STORY),
+		item(OPTION_SKIP_STORY),
+		item(OPTION_UPDATE_JIRA),
+		item(OPTION_EXPORT_HISTORY),
+		item(OPTION_HAND_OVER),
+	}
+	for _, c := range d.Cards {
+		items = append(items, cardItem{card: c, title: d.Title(c)})
+	}
+	return items
+}
+
 func (i item) FilterValue() string                               { return "" }
+func (i cardItem) FilterValue() string                           { return "" }
 func (d itemDelegate) Height() int                               { return 1 }
 func (d itemDelegate) Spacing() int                              { return 0 }
 func (d itemDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
 func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
-	i, ok := listItem.(item)
-	if !ok {
+	var str string
+	switch i := listItem.(type) {
+	case item:
+		str = string(i)
+	case cardItem:
+		str = i.title
+	case deckItem:
+		str = i.title()
+	default:
 		return
 	}
 
-	str := string(i)
 	fn := itemStyle.Render
 	if index == m.Index() {
 		fn = func(s string) string {
@@ -79,19 +109,37 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	fmt.Fprint(w, fn(str))
 }
 
-func (m *model) handleMenuEvents() {
+func (m *model) handleMenuEvents() tea.Cmd {
 	switch m.readChoice() {
 	case OPTION_SET_DESCRIPTION:
 		m.editDescription = true
 		m.description.Focus()
 	case OPTION_CLEAR_VOTES:
-		m.clearVotes(true)
+		return m.clearVotes()
 	case OPTION_SHOW_VOTES:
-		m.displayVotes(true)
+		return m.displayVotes()
+	case OPTION_REVOTE:
+		return m.revote()
+	case OPTION_CHANGE_DECK:
+		m.pickDeck()
+	case OPTION_LOAD_BACKLOG:
+		m.editBacklog()
+	case OPTION_NEXT_STORY:
+		return m.moveStory(m.session.NextStory)
+	case OPTION_PREVIOUS_STORY:
+		return m.moveStory(m.session.PreviousStory)
+	case OPTION_SKIP_STORY:
+		return m.moveStory(m.session.SkipStory)
 	case OPTION_UPDATE_JIRA:
+		return m.updateJira()
+	case OPTION_EXPORT_HISTORY:
+		m.exportHistory()
+	case OPTION_HAND_OVER:
+		m.editHandOver()
 	default:
-		m.updateVote(m.cr.Self, m.choice, true)
+		return m.castVote(m.choice)
 	}
+	return nil
 }
 
 func (m *model) updateMenu(msg tea.Msg) tea.Cmd {
@@ -101,10 +149,24 @@ func (m *model) updateMenu(msg tea.Msg) tea.Cmd {
 }
 
 func (m *model) readChoice() string {
-	i, ok := m.menu.SelectedItem().(item)
-	if ok {
+	switch i := m.menu.SelectedItem().(type) {
+	case item:
 		m.choice = string(i)
+	case cardItem:
+		m.choice = i.card
 	}
 
 	return m.choice
 }
+
+// syncMenu shows the cards of the deck the room is using.
+func (m *model) syncMenu() {
+	d := m.session.State().Deck
+	if reflect.DeepEqual(d, m.deck) {
+		return
+	}
+	m.deck = d
+	items := menuItems(d)
+	m.menu.SetItems(items)
+	m.menu.SetHeight(len(items) + 6)
+}
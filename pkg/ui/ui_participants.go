@@ -2,24 +2,15 @@ package ui
 
 import (
 	"fmt"
-	"sort"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/session"
 )
 
-type participant struct {
-	id              peer.ID
-	nick            string
-	currentVote     string
-	heartbeatMisses int
-}
-
 func NewTable() table.Model {
 	columns := []table.Column{
 		{Title: "Today we have with us", Width: 50},
@@ -47,68 +38,61 @@ func NewTable() table.Model {
 	return t
 }
 
-func (m *model) updateParticipants(msg *chatroom.ChatMessage) {
-	sid := shortID(peer.ID(msg.SenderID))
-	m.participants[sid] = participant{
-		id:              peer.ID(msg.SenderID),
-		nick:            msg.SenderNick,
-		heartbeatMisses: 0,
-		currentVote:     m.participants[sid].currentVote,
-	}
-}
+func (m *model) refreshTable() {
+	state := m.session.State()
 
-func (m *model) refreshPeers() {
 	rows := []table.Row{}
-	for k, p := range m.participants {
-		if p.id == m.cr.Self {
-			continue
+	for _, p := range state.Participants {
+		nick := p.Nick
+		if p.Self {
+			nick += " (you)"
 		}
-		if p.heartbeatMisses > 15 {
-			delete(m.participants, k)
-			continue
+		if p.Facilitator {
+			nick += " 👑"
 		}
-		rows = append(rows, table.Row{p.nick, m.estimationStatus(&p)})
-		p.heartbeatMisses++
-		m.participants[k] = p
+		if p.Outlier {
+			nick += " ❗"
+		}
+		if p.Away {
+			nick += fmt.Sprintf(" 💤 %s", lastSeen(p.LastSeen))
+		}
+		rows = append(rows, table.Row{nick, estimationStatus(&p, state.Revealed)})
 	}
-
-	sort.Slice(rows, func(i, j int) bool {
-		return rows[i][0] < rows[j][0]
-	})
-
-	self := table.Row{m.self().nick, m.estimationStatus(m.self())}
-	rowSelf := []table.Row{self}
-	rows = append(rowSelf, rows...)
 	m.table.SetRows(rows)
+	m.table.SetHeight(len(rows))
 }
 
 func (m *model) updateParticipantsTable(msg tea.Msg) (cmd tea.Cmd) {
-	m.refreshPeers()
-	m.table.SetHeight(len(m.participants))
+	m.refreshTable()
 	m.table, cmd = m.table.Update(msg)
 
 	return
 }
 
-func (m *model) estimationStatus(p *participant) string {
-	if p.currentVote == "" {
+// lastSeen tells how long ago a participant was seen, e.g. "2m ago".
+func lastSeen(t time.Time) string {
+	return time.Since(t).Truncate(time.Second).String() + " ago"
+}
+
+func estimationStatus(p *session.Participant, revealed bool) string {
+	if !p.Voted {
 		return "-"
 	}
 
-	if !m.showVotes {
-		return "✅"
+	if p.Mismatch {
+		return "❌"
 	}
 
-	val, err := parseVote(p.currentVote)
-	if err != nil {
-		return "-"
+	if !revealed {
+		return "✅"
 	}
 
-	return fmt.Sprintf("%d", val)
-}
+	switch p.Vote {
+	case "":
+		return "⏳"
+	case deck.NoClue:
+		return "🤷"
+	}
 
-func parseVote(currVote string) (int, error) {
-	vote := strings.Split(currVote, " ")[0]
-	val, err := strconv.Atoi(vote)
-	return val, err
+	return p.Vote
 }
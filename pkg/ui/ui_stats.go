@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+	"github.com/renato0307/p2p-estimator/pkg/stats"
+)
+
+var verdictStyles = map[stats.Verdict]lipgloss.Style{
+	stats.Consensus: lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+	stats.Close:     lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+	stats.Split:     lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
+}
+
+// statsView shows the statistics of the votes revealed.
+func statsView(d deck.Deck, st session.Statistics) string {
+	modes := make([]string, 0, len(st.Mode))
+	for _, v := range st.Mode {
+		modes = append(modes, d.Nearest(v))
+	}
+
+	lines := []string{
+		fmt.Sprintf("Average: %s  Median: %s  Mode: %s",
+			d.Format(st.Mean), d.Format(st.Median), strings.Join(modes, ", ")),
+		fmt.Sprintf("Min: %s  Max: %s  Std dev: %.2f",
+			d.Nearest(st.Min), d.Nearest(st.Max), st.StdDev),
+		fmt.Sprintf("Nearest card: %s  ", d.Title(st.Nearest)) +
+			verdictStyles[st.Verdict].Render(verdictTitle(st.Verdict)),
+	}
+	return lipgloss.JoinVertical(lipgloss.Center, lines...) + "\n"
+}
+
+func verdictTitle(v stats.Verdict) string {
+	switch v {
+	case stats.Consensus:
+		return "Consensus 🎉"
+	case stats.Close:
+		return "Almost there 👌"
+	default:
+		return "No consensus, outliers explain ❗"
+	}
+}
+
+// roundsView shows how the estimates of the story converged between the
+// rounds of votes.
+func roundsView(state session.State, current *session.Statistics) string {
+	if len(state.PastRounds) == 0 {
+		return ""
+	}
+
+	lines := []string{"Rounds of this story"}
+	for _, r := range state.PastRounds {
+		lines = append(lines, roundLine(state.Deck, r.Number, votesOf(r.Votes), r.Statistics))
+	}
+	if state.Revealed {
+		lines = append(lines, roundLine(state.Deck, state.StoryRound, votesOf(state.Participants), current))
+	} else {
+		lines = append(lines, fmt.Sprintf("%d. voting...", state.StoryRound))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func roundLine(d deck.Deck, number int, votes []string, st *session.Statistics) string {
+	line := fmt.Sprintf("%d. %s", number, strings.Join(votes, " "))
+	if st == nil {
+		return line
+	}
+	return line + fmt.Sprintf(" → %s ", d.Format(st.Mean)) +
+		verdictStyles[st.Verdict].Render(string(st.Verdict))
+}
+
+func votesOf(participants []session.Participant) []string {
+	votes := []string{}
+	for _, p := range participants {
+		if p.Voted && p.Vote != "" {
+			votes = append(votes, p.Vote)
+		}
+	}
+	return votes
+}
@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/renato0307/p2p-estimator/pkg/chatroom"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/presence"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(chatroom.ChatMessageType, int, interface{}) error {
+	return nil
+}
+
+func newTestModel() model {
+	s := session.New(nopPublisher{}, presence.NewTracker(), "self", "alice", deck.Default)
+	return model{
+		session:       s,
+		deck:          deck.Default,
+		deckPicker:    NewDeckPicker(nil),
+		jiraKey:       NewJiraKeyInput(),
+		backlogInput:  NewBacklogInput(),
+		handOverInput: NewHandOverInput(),
+		menu:          NewMenu(deck.Default),
+		table:         NewTable(),
+		description:   NewDescriptionInput(),
+		activityView:  NewActivityView(),
+	}
+}
+
+func quits(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	return cmd() == tea.Quit()
+}
+
+func TestKeysInInputs(t *testing.T) {
+	q := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}
+	esc := tea.KeyMsg{Type: tea.KeyEsc}
+
+	tests := []struct {
+		name  string
+		start func(m *model)
+		value func(m model) string
+	}{
+		{
+			name:  "description",
+			start: func(m *model) { m.editDescription = true; m.description.Focus() },
+			value: func(m model) string { return m.description.Value() },
+		},
+		{
+			name:  "jira key",
+			start: func(m *model) { m.editJiraKey = true; m.jiraKey.Focus() },
+			value: func(m model) string { return m.jiraKey.Value() },
+		},
+		{
+			name:  "backlog",
+			start: func(m *model) { m.editBacklogInput = true; m.backlogInput.Focus() },
+			value: func(m model) string { return m.backlogInput.Value() },
+		},
+		{
+			name:  "hand over",
+			start: func(m *model) { m.editHandOverInput = true; m.handOverInput.Focus() },
+			value: func(m model) string { return m.handOverInput.Value() },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel()
+			tt.start(&m)
+
+			next, cmd := m.Update(q)
+			m = next.(model)
+			if quits(cmd) {
+				t.Fatal("typing q quits")
+			}
+			if v := tt.value(m); v != "q" {
+				t.Errorf("input = %q, want %q", v, "q")
+			}
+
+			next, _ = m.Update(esc)
+			m = next.(model)
+			if m.editing() {
+				t.Error("still editing after esc")
+			}
+			if _, cmd := m.Update(q); !quits(cmd) {
+				t.Error("q doesn't quit once the input is left")
+			}
+		})
+	}
+}
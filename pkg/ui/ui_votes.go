@@ -1,86 +1,24 @@
 package ui
 
 import (
-	"github.com/renato0307/p2p-estimator/pkg/chatroom"
-
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/libp2p/go-libp2p/core/peer"
 )
 
-func (m *model) calculateVotesAverage() float32 {
-	var validVotes float32
-	var sum float32
-	for _, p := range m.participants {
-		val, err := parseVote(p.currentVote)
-		if err != nil {
-			continue
-		}
-		validVotes++
-		sum += float32(val)
-	}
-	return sum / validVotes
-}
-
-func (m *model) updateVote(pid peer.ID, vote string, sendMsg bool) tea.Cmd {
-	m.setVote(pid, vote)
-
-	var numberOfVotes int
-	for _, p := range m.participants {
-		if p.currentVote != "" {
-			numberOfVotes++
-		}
-	}
-	m.showVotes = numberOfVotes == len(m.participants)
-	if m.showVotes {
-		m.refreshPeers()
-		m.updateParticipantsTable(nil)
-	}
-
-	if !sendMsg {
-		return nil
-	}
-
-	err := m.cr.Publish(chatroom.SendVote, vote)
-	if err != nil {
-		panic(err)
-	}
-	return nil
+func (m *model) castVote(vote string) tea.Cmd {
+	return m.do("vote", "", func() error {
+		return m.session.Vote(vote)
+	})
 }
 
-func (m *model) clearVotes(sendMsg bool) tea.Cmd {
-	for i, p := range m.participants {
-		p.currentVote = ""
-		m.participants[i] = p
-	}
-	m.showVotes = false
-
-	if !sendMsg {
-		return nil
-	}
-
-	err := m.cr.Publish(chatroom.ClearVotes, "")
-	if err != nil {
-		panic(err)
-	}
-	return nil
+func (m *model) clearVotes() tea.Cmd {
+	return m.do("clear the votes", "", m.session.Clear)
 }
 
-func (m *model) displayVotes(sendMsg bool) tea.Cmd {
-	m.showVotes = true
-
-	if !sendMsg {
-		return nil
-	}
-
-	err := m.cr.Publish(chatroom.ShowVotes, "")
-	if err != nil {
-		panic(err)
-	}
-	return nil
+func (m *model) displayVotes() tea.Cmd {
+	return m.do("show the votes", "", m.session.Reveal)
 }
 
-func (m *model) setVote(pid peer.ID, vote string) {
-	p := m.participants[shortID(pid)]
-	p.currentVote = vote
-	m.participants[shortID(pid)] = p
+// revote keeps the votes revealed as a past round and votes again.
+func (m *model) revote() tea.Cmd {
+	return m.do("vote again", "", m.session.Revote)
 }
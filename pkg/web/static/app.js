@@ -0,0 +1,121 @@
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+let myVote = "";
+
+function command(name, arg) {
+  const body = new URLSearchParams();
+  if (arg !== undefined) {
+    body.set("arg", arg);
+  }
+  fetch("commands/" + name, { method: "POST", body })
+    .then(async (res) => {
+      $("notice").textContent = res.status === 204 ? "" : (await res.text());
+    })
+    .catch((err) => { $("notice").textContent = err; });
+}
+
+function estimation(p, revealed) {
+  if (!p.Voted) return "-";
+  if (p.Mismatch) return "❌";
+  if (!revealed) return "✅";
+  if (p.Vote === "") return "⏳";
+  if (p.Vote === "?") return "🤷";
+  return p.Vote;
+}
+
+function verdictClass(verdict) {
+  return verdict.replace(" ", "-");
+}
+
+function render(v) {
+  const s = v.state;
+  $("room").textContent = v.room;
+  $("connectivity").textContent = v.connectivity ? "🌐 " + v.connectivity : "";
+  if (v.notice) {
+    $("notice").textContent = v.notice;
+  }
+
+  const decks = $("deck");
+  decks.replaceChildren(...v.decks.map((name) => new Option(name, name, false, name === s.Deck.name)));
+
+  $("participants").replaceChildren(...s.Participants.map((p) => {
+    const row = document.createElement("tr");
+    let nick = p.Nick;
+    if (p.Self) nick += " (you)";
+    if (p.Facilitator) nick += " 👑";
+    if (p.Outlier) nick += " ❗";
+    if (p.Away) nick += " 💤";
+    for (const text of [nick, estimation(p, s.Revealed)]) {
+      const cell = document.createElement("td");
+      cell.textContent = text;
+      row.appendChild(cell);
+    }
+    return row;
+  }));
+
+  const self = s.Participants.find((p) => p.Self);
+  if (!self || !self.Voted) {
+    myVote = "";
+  }
+  $("cards").replaceChildren(...s.Deck.cards.map((card) => {
+    const button = document.createElement("button");
+    button.textContent = card;
+    button.className = card === myVote ? "selected" : "";
+    button.onclick = () => { myVote = card; command("vote", card); };
+    return button;
+  }));
+
+  const stats = $("stats");
+  stats.replaceChildren();
+  if (s.Statistics) {
+    const st = s.Statistics;
+    stats.textContent = `Average: ${st.Mean.toFixed(2)}  Median: ${st.Median.toFixed(2)}  ` +
+      `Std dev: ${st.StdDev.toFixed(2)}  Nearest card: ${st.Nearest}  `;
+    const verdict = document.createElement("span");
+    verdict.className = verdictClass(st.Verdict);
+    verdict.textContent = st.Verdict;
+    stats.appendChild(verdict);
+  }
+
+  const description = $("description");
+  if (document.activeElement !== description) {
+    description.value = s.Description;
+  }
+
+  $("rounds").replaceChildren(...(s.PastRounds || []).map((r) => {
+    const line = document.createElement("div");
+    const votes = (r.Votes || []).map((p) => p.Vote).filter((v) => v).join(" ");
+    line.textContent = `Round ${r.Number}: ${votes}` + (r.Statistics ? ` → ${r.Statistics.Mean.toFixed(2)} ${r.Statistics.Verdict}` : "");
+    return line;
+  }));
+
+  $("backlog").replaceChildren(...(s.Backlog || []).map((story, i) => {
+    const item = document.createElement("li");
+    item.textContent = (story.key ? story.key + ": " : "") + story.title + (story.estimate ? ` (${story.estimate})` : "");
+    item.className = i === s.Current ? "current" : "";
+    return item;
+  }));
+
+  $("requests").replaceChildren(...(s.Requests || []).map((r) => {
+    const item = document.createElement("li");
+    item.textContent = `${r.Nick} asks to ${r.Action}`;
+    return item;
+  }));
+}
+
+document.querySelectorAll("nav button").forEach((button) => {
+  button.onclick = () => command(button.dataset.command);
+});
+
+$("description-form").onsubmit = (e) => {
+  e.preventDefault();
+  command("description", $("description").value);
+  $("description").blur();
+};
+
+$("deck").onchange = (e) => command("deck", e.target.value);
+
+const events = new EventSource("events");
+events.onmessage = (e) => render(JSON.parse(e.data));
+events.onerror = () => { $("connectivity").textContent = "🔌 reconnecting to the peer..."; };
@@ -0,0 +1,220 @@
+// Package web serves a browser UI for the estimation session from the local
+// peer. The browser only talks to localhost, the room is still reached
+// through the peer.
+package web
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/renato0307/p2p-estimator/pkg/api"
+	"github.com/renato0307/p2p-estimator/pkg/control"
+	"github.com/renato0307/p2p-estimator/pkg/deck"
+	"github.com/renato0307/p2p-estimator/pkg/session"
+)
+
+// PollInterval is how often the state of the room is checked for changes
+// to push to the browsers.
+const PollInterval = 250 * time.Millisecond
+
+// KeepAliveInterval is how often an idle event stream is kept alive.
+const KeepAliveInterval = 15 * time.Second
+
+//go:embed static
+var static embed.FS
+
+// Server is the web UI of an estimation session.
+type Server struct {
+	session     *session.EstimationSession
+	control     *control.Controller
+	decks       []deck.Deck
+	room        string
+	allowRemote bool
+
+	mu           sync.Mutex
+	connectivity string
+	notice       string
+}
+
+// view is what the browser shows, sent on every change.
+type view struct {
+	Room         string        `json:"room"`
+	Connectivity string        `json:"connectivity"`
+	Notice       string        `json:"notice"`
+	Decks        []string      `json:"decks"`
+	State        session.State `json:"state"`
+}
+
+// New creates the web UI for the session in the room. The decks are the
+// ones the room can be switched to.
+func New(s *session.EstimationSession, room string, decks []deck.Deck) *Server {
+	return &Server{
+		session: s,
+		control: control.New(s, decks),
+		decks:   decks,
+		room:    room,
+	}
+}
+
+// SetConnectivity updates the connectivity status shown to the user.
+func (srv *Server) SetConnectivity(status string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.connectivity = status
+}
+
+// Notify shows a notice to the user.
+func (srv *Server) Notify(notice string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.notice = notice
+}
+
+// AllowRemote lets the web UI listen on addresses other machines can
+// reach.
+func (srv *Server) AllowRemote() {
+	srv.allowRemote = true
+}
+
+// ListenAndServe serves the web UI until the context is done. Addresses
+// without a host, like ":8080", only listen on localhost, and addresses
+// other machines can reach are refused with api.ErrNotLoopback unless
+// AllowRemote was called.
+func (srv *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if !srv.allowRemote {
+		if err := api.CheckLocal(addr); err != nil {
+			return err
+		}
+	}
+	hs := &http.Server{
+		Addr:              api.LocalAddr(addr),
+		Handler:           api.HostCheck(addr, srv.Handler()),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		hs.Close()
+	}()
+
+	err := hs.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Handler returns the handler of the web UI.
+func (srv *Server) Handler() http.Handler {
+	root, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(root)))
+	mux.HandleFunc("/events", srv.events)
+	mux.HandleFunc("/commands/", srv.command)
+	return mux
+}
+
+// events streams the view to the browser as server-sent events, every time
+// it changes.
+func (srv *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	poll := time.NewTicker(PollInterval)
+	defer poll.Stop()
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+
+	var last []byte
+	for {
+		data, err := json.Marshal(srv.view())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !bytes.Equal(data, last) {
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+			last = data
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-poll.C:
+		}
+	}
+}
+
+// command runs the command in the path, e.g. POST /commands/vote with the
+// card in the arg form value.
+func (srv *Server) command(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// browsers send the origin, forms posted by other sites are rejected
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	command := strings.TrimPrefix(r.URL.Path, "/commands/")
+	err := srv.control.Exec(command, r.FormValue("arg"))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, control.ErrUnknownCommand):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, session.ErrRequested):
+		// the facilitator got the request, it's not a failure
+		http.Error(w, err.Error(), http.StatusAccepted)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func (srv *Server) view() view {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	v := view{
+		Room:         srv.room,
+		Connectivity: srv.connectivity,
+		Notice:       srv.notice,
+		State:        srv.session.State(),
+	}
+	for _, d := range srv.decks {
+		v.Decks = append(v.Decks, d.Name)
+	}
+	return v
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Exit codes, so scripts can tell why the estimator stopped.
+const (
+	// ExitFailure is used when something fails while running.
+	ExitFailure = 1
+	// ExitUsage is used for invalid flags, like the flag package does.
+	ExitUsage = 2
+	// ExitNetwork is used when the peer can't listen or join the network.
+	ExitNetwork = 3
+)
+
+// exit prints why the estimator can't go on and exits with the code.
+func exit(code int, format string, args ...interface{}) {
+	printErr(format+"\n", args...)
+	os.Exit(code)
+}
+
+// checkListenAddr validates the -addr and -port flags and checks the port
+// is free before creating the host, whose errors don't tell what went
+// wrong. It returns the exit code to use when they can't be used.
+func checkListenAddr(addr, port string) (int, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.To4() == nil {
+		return ExitUsage, fmt.Errorf("invalid -addr %q, use an IPv4 address of this machine or 0.0.0.0 for all of them", addr)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return ExitUsage, fmt.Errorf("invalid -port %q, use a number up to 65535 or 0 for a random port", port)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	l, err := net.Listen("tcp4", net.JoinHostPort(addr, port))
+	if err == nil {
+		l.Close()
+		return 0, nil
+	}
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		return ExitNetwork, fmt.Errorf("port %s is already in use, pick another one with -port or use -port 0 for a random one", port)
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return ExitNetwork, fmt.Errorf("address %s is not one of this machine, use one of its addresses with -addr or 0.0.0.0 for all of them", addr)
+	case errors.Is(err, syscall.EACCES):
+		return ExitNetwork, fmt.Errorf("not allowed to listen on port %s, use a port above 1023 or -port 0 for a random one", port)
+	}
+	return ExitNetwork, fmt.Errorf("can't listen on %s:%s: %w", addr, port, err)
+}